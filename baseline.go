@@ -0,0 +1,82 @@
+package loggedrwmutex
+
+import (
+	"sync"
+	"time"
+)
+
+// baselineAcquired performs the lock operation on the baseline mutex base after the
+// real lock has been acquired, so the baseline is never contended, and
+// accumulates the difference to the duration of the wrapped operation since start.
+func (m *LoggedSyncRWMutex) baselineAcquired(base *sync.RWMutex, start time.Time, write bool) {
+	wrapped := now().Sub(start)
+	t := now()
+	if write {
+		base.Lock()
+	} else {
+		base.RLock()
+	}
+	d := now().Sub(t)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.feat()
+	if write {
+		f.baselineLocked = true
+	} else {
+		f.baselineReaders++
+	}
+	f.overhead += wrapped - d
+}
+
+// baselineReleasing returns the baseline mutex if it is held
+// and has to be released along with the real lock, nil otherwise.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) baselineReleasing(write bool) *sync.RWMutex {
+	f := m.ext
+	if f == nil {
+		return nil
+	}
+	if write {
+		if !f.baselineLocked {
+			return nil
+		}
+		f.baselineLocked = false
+		return &f.baseline
+	}
+	if f.baselineReaders == 0 {
+		return nil
+	}
+	f.baselineReaders--
+	return &f.baseline
+}
+
+// baselineRelease releases the baseline mutex base before the real lock,
+// so the next holder never waits for it, and returns how long it took.
+func baselineRelease(base *sync.RWMutex, write bool) time.Duration {
+	t := now()
+	if write {
+		base.Unlock()
+	} else {
+		base.RUnlock()
+	}
+	return now().Sub(t)
+}
+
+// baselineReleased accumulates the overhead of a release.
+// The wrapped duration since rs.start includes the baseline release itself,
+// which is subtracted before comparing.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) baselineReleased(rs releaseState) {
+	m.feat().overhead += now().Sub(rs.start) - 2*rs.baseline
+}
+
+// OverheadEstimate returns the accumulated extra time spent in
+// Lock, Unlock, RLock and RUnlock compared to a plain sync.RWMutex.
+// Requires CompareBaseline, which roughly doubles the cost of every operation.
+// Time spent waiting for a contended lock is included, as the baseline is never contended.
+func (m *LoggedSyncRWMutex) OverheadEstimate() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.view().overhead
+}
@@ -0,0 +1,37 @@
+package loggedrwmutex
+
+import (
+	"testing"
+)
+
+func TestOverheadEstimate(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestOverheadEstimate"}
+	mux.Lock()
+	mux.Unlock()
+	if o := mux.OverheadEstimate(); o != 0 {
+		t.Errorf("OverheadEstimate should be 0 without CompareBaseline, got %v", o)
+	}
+
+	mux.CompareBaseline = true
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RLock()
+		mux.RUnlock()
+		mux.RUnlock()
+	}
+	if o := mux.OverheadEstimate(); o == 0 {
+		t.Error("OverheadEstimate should be nonzero with CompareBaseline")
+	}
+	if mux.ext.baselineLocked || mux.ext.baselineReaders != 0 {
+		t.Error("baseline mutex should be released")
+	}
+
+	// disabling while held does not release the baseline twice
+	mux.Lock()
+	mux.CompareBaseline = false
+	mux.Unlock()
+	mux.Lock()
+	mux.Unlock()
+}
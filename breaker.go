@@ -0,0 +1,46 @@
+package loggedrwmutex
+
+import "time"
+
+// logAllowed applies AutoDisableRate to one log line and reports whether it may be written.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) logAllowed() bool {
+	if m.AutoDisableRate <= 0 {
+		return true
+	}
+	t := now()
+	f := m.feat()
+	if !f.autoDisabledAt.IsZero() {
+		if m.AutoDisableCooldown <= 0 || t.Sub(f.autoDisabledAt) < m.AutoDisableCooldown {
+			return false
+		}
+		f.autoDisabledAt = time.Time{}
+		f.rateStart, f.rateLines = t, 0
+	}
+	if t.Sub(f.rateStart) >= time.Second {
+		f.rateStart, f.rateLines = t, 0
+	}
+	f.rateLines++
+	if f.rateLines <= m.AutoDisableRate {
+		return true
+	}
+	f.autoDisabledAt = t
+	if m.AutoDisableCooldown > 0 {
+		m.warnf(SeverityWarn, "log-storm", "more than %d log lines per second, logging disabled for %v", m.AutoDisableRate, m.AutoDisableCooldown)
+	} else {
+		m.warnf(SeverityWarn, "log-storm", "more than %d log lines per second, logging disabled", m.AutoDisableRate)
+	}
+	return false
+}
+
+// AutoDisabled reports whether logging of the mutex has been disabled by AutoDisableRate.
+// Counting continues while logging is disabled.
+func (m *LoggedSyncRWMutex) AutoDisabled() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	if f.autoDisabledAt.IsZero() {
+		return false
+	}
+	return m.AutoDisableCooldown <= 0 || now().Sub(f.autoDisabledAt) < m.AutoDisableCooldown
+}
@@ -0,0 +1,58 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutoDisableRate(t *testing.T) {
+	clock := useFakeClock(t)
+	out := captureOutput(t)
+	warns := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestAutoDisableRate", DebugAll: true, AutoDisableRate: 10, AutoDisableCooldown: time.Minute}
+
+	for i := 0; i < 50; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	if n := len(lines(out)); n != 10 {
+		t.Errorf("should log 10 lines before disabling, got %d", n)
+	}
+	if got := lines(warns); len(got) != 1 || !strings.Contains(got[0], "log-storm") {
+		t.Errorf("should warn once about the log storm, got %q", got)
+	}
+	if !mux.AutoDisabled() {
+		t.Error("AutoDisabled should report true")
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 50 || st.TotalUnlocked != 50 {
+		t.Errorf("counting should continue while disabled, got %+v", st)
+	}
+
+	// re-enabled after the cooldown
+	out.Reset()
+	clock.advance(time.Minute)
+	if mux.AutoDisabled() {
+		t.Error("AutoDisabled should report false after the cooldown")
+	}
+	mux.Lock()
+	mux.Unlock()
+	if n := len(lines(out)); n != 2 {
+		t.Errorf("should log again after the cooldown, got %d lines", n)
+	}
+
+	// a low rate never trips
+	clock.advance(time.Minute)
+	out.Reset()
+	for i := 0; i < 20; i++ {
+		mux.Lock()
+		mux.Unlock()
+		clock.advance(200 * time.Millisecond)
+	}
+	if n := len(lines(out)); n != 40 {
+		t.Errorf("should log all lines below the rate, got %d", n)
+	}
+	if n := len(lines(warns)); n != 1 {
+		t.Errorf("should not warn again, got %d warnings", n)
+	}
+}
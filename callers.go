@@ -0,0 +1,99 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// maxSampleDepth limits the number of frames of a sampled caller stack.
+const maxSampleDepth = 32
+
+// sampleCaller records the stack of the goroutine acquiring the lock
+// every SampleCallersEvery acquisitions.
+// skip is the number of frames between sampleCaller and the caller of Lock or RLock.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) sampleCaller(skip int) {
+	if m.SampleCallersEvery == 0 || (uint64(m.totalLocked)+uint64(m.totalrLocked))%m.SampleCallersEvery != 0 {
+		return
+	}
+	var pcs [maxSampleDepth]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	var funcs []string
+	for {
+		frame, more := frames.Next()
+		if frame.Function != "" {
+			funcs = append(funcs, frame.Function)
+		}
+		if !more {
+			break
+		}
+	}
+	// folded stacks start at the root
+	for i, j := 0, len(funcs)-1; i < j; i, j = i+1, j-1 {
+		funcs[i], funcs[j] = funcs[j], funcs[i]
+	}
+	f := m.feat()
+	if f.callerSamples == nil {
+		f.callerSamples = make(map[string]uint64)
+	}
+	key := strings.Join(funcs, ";")
+	if _, ok := f.callerSamples[key]; !ok && full(len(f.callerSamples)) {
+		m.evictCallerSample()
+	}
+	f.callerSamples[key]++
+}
+
+// WriteFolded writes the sampled caller stacks (see SampleCallersEvery) to w
+// in the folded stack format "root;caller;leaf count" read by flamegraph tools.
+func (m *LoggedSyncRWMutex) WriteFolded(w io.Writer) error {
+	m.mu.Lock()
+	f := m.view()
+	stacks := make([]string, 0, len(f.callerSamples))
+	for stack := range f.callerSamples {
+		stacks = append(stacks, stack)
+	}
+	counts := make(map[string]uint64, len(stacks))
+	for _, stack := range stacks {
+		counts[stack] = f.callerSamples[stack]
+	}
+	m.mu.Unlock()
+
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, counts[stack]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callerDepth returns the number of frames of the calling goroutine from the
+// first frame outside of this package, see caller, to the bottom of the stack,
+// so every operation of one call site reports the same depth.
+func callerDepth() int {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, 2*len(pcs))
+	}
+	depth, outside := 0, false
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		outside = outside || outsidePackage(f)
+		if outside {
+			depth++
+		}
+		if !more {
+			return depth
+		}
+	}
+}
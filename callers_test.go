@@ -0,0 +1,119 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var foldedLine = regexp.MustCompile(`^[^ ;]+(;[^ ;]+)* [0-9]+$`)
+
+func TestWriteFolded(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestWriteFolded"}
+	mux.feat().callerSamples = map[string]uint64{
+		"main.main;app.handle;app.store": 3,
+		"main.main;app.load":             1,
+	}
+	var buf bytes.Buffer
+	if err := mux.WriteFolded(&buf); err != nil {
+		t.Fatalf("WriteFolded failed: %v", err)
+	}
+	want := "main.main;app.handle;app.store 3\nmain.main;app.load 1\n"
+	if buf.String() != want {
+		t.Errorf("WriteFolded should write %q, got %q", want, buf.String())
+	}
+}
+
+func lockFromHelper(m *LoggedSyncRWMutex) {
+	m.Lock()
+	m.Unlock()
+}
+
+func TestSampleCallers(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestSampleCallers", SampleCallersEvery: 2}
+	for i := 0; i < 10; i++ {
+		lockFromHelper(mux)
+	}
+	var buf bytes.Buffer
+	mux.WriteFolded(&buf)
+	got := lines(&buf)
+	if len(got) != 1 {
+		t.Fatalf("should sample one call site, got %q", got)
+	}
+	if !foldedLine.MatchString(got[0]) {
+		t.Errorf("folded line is malformed: %q", got[0])
+	}
+	if !strings.HasSuffix(got[0], ".TestSampleCallers;github.com/go-while/go-loggedrwmutex.lockFromHelper 5") {
+		t.Errorf("stack should end in the caller of Lock with 5 samples, got %q", got[0])
+	}
+}
+
+//go:noinline
+func lockAtDepth(m *LoggedSyncRWMutex, n int) {
+	if n > 1 {
+		lockAtDepth(m, n-1)
+		return
+	}
+	m.Lock()
+	m.Unlock()
+}
+
+var depthField = regexp.MustCompile(` depth=([0-9]+)$`)
+
+func TestLogStackDepth(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestLogStackDepth", DebugAll: true, LogStackDepth: true}
+
+	want := callerDepth()
+	mux.Lock()
+	mux.Unlock()
+	lockAtDepth(mux, 1)
+	lockAtDepth(mux, 6)
+
+	var depths []int
+	for _, line := range lines(buf) {
+		match := depthField.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("line should end with depth=, got %q", line)
+		}
+		d, _ := strconv.Atoi(match[1])
+		depths = append(depths, d)
+	}
+	if len(depths) != 6 {
+		t.Fatalf("should log 6 lines, got %d", len(depths))
+	}
+	if depths[0] != want || depths[1] != want {
+		t.Errorf("depth of a direct call should be %d, got %v", want, depths[:2])
+	}
+	if depths[2] != want+1 || depths[3] != want+1 {
+		t.Errorf("depth from lockAtDepth should be %d, got %v", want+1, depths[2:4])
+	}
+	if depths[4] != depths[2]+5 || depths[5] != depths[3]+5 {
+		t.Errorf("depth should grow by 5 with 5 more nested calls, got %v", depths)
+	}
+
+	// the waiting line of a blocked Lock has the depth of its Lock line
+	buf.Reset()
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Lock()
+		mux.Unlock()
+	}()
+	for mux.Snapshot().WriterWaiters == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	mux.Unlock()
+	<-done
+	got := lines(buf)
+	if len(got) != 5 || !strings.Contains(got[1], " waiting ") {
+		t.Fatalf("should log a waiting line, got %q", got)
+	}
+	if waiting, locked := depthField.FindStringSubmatch(got[1]), depthField.FindStringSubmatch(got[3]); waiting == nil || locked == nil || waiting[1] != locked[1] {
+		t.Errorf("waiting and Lock line should have the same depth, got %q", got)
+	}
+}
@@ -0,0 +1,135 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// totals returns the total counters under the internal mutex.
+func (m *LoggedSyncRWMutex) totals() (locked, unlocked, rlocked, runlocked uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return uint64(m.totalLocked), uint64(m.totalUnlocked), uint64(m.totalrLocked), uint64(m.totalrUnlocked)
+}
+
+// CheckBalanced runs fn and returns an error if fn did not unlock every lock
+// and read-unlock every read lock it took on the mutex.
+// Meant for tests: operations of other goroutines during fn are counted as well.
+func (m *LoggedSyncRWMutex) CheckBalanced(fn func()) error {
+	l0, u0, rl0, ru0 := m.totals()
+	fn()
+	l1, u1, rl1, ru1 := m.totals()
+	locks, unlocks := l1-l0, u1-u0
+	rlocks, runlocks := rl1-rl0, ru1-ru0
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if locks != unlocks {
+		return m.misuseError(MisuseUnbalanced, 0, "%d Lock vs %d Unlock", locks, unlocks)
+	}
+	if rlocks != runlocks {
+		return m.misuseError(MisuseUnbalanced, 0, "%d RLock vs %d RUnlock", rlocks, runlocks)
+	}
+	return nil
+}
+
+// CheckInvariants returns an error if the counters of the mutex are inconsistent,
+// which indicates a bug in the counting or a corrupted state.
+func (m *LoggedSyncRWMutex) CheckInvariants() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e := m.checkInvariants(0); e != nil {
+		return e
+	}
+	return nil
+}
+
+// checkInvariants is CheckInvariants, op is the operation checking, 0 for none.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) checkInvariants(op byte) *MisuseError {
+	if m.totalLocked+m.resetLocked < m.totalUnlocked {
+		return m.misuseError(MisuseInvariant, op, "more unlocks than locks: %d/%d", m.totalUnlocked, m.totalLocked)
+	}
+	if m.totalrLocked+m.resetRLocked < m.totalrUnlocked {
+		return m.misuseError(MisuseInvariant, op, "more read unlocks than read locks: %d/%d", m.totalrUnlocked, m.totalrLocked)
+	}
+	if m.lockedCount > 1 {
+		return m.misuseError(MisuseInvariant, op, "write lock held %d times", m.lockedCount)
+	}
+	if m.lockedCount > 0 && m.rLockedCount > 0 {
+		return m.misuseError(MisuseInvariant, op, "write lock held with %d readers", m.rLockedCount)
+	}
+	return nil
+}
+
+// verify reports broken invariants after op as misuse if DebugInvariants is set.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) verify(op byte) {
+	if !m.DebugInvariants {
+		return
+	}
+	if e := m.checkInvariants(op); e != nil {
+		m.reportMisuse(e)
+	}
+}
+
+// WaitReaders polls the mutex until exactly k read locks are active and reports
+// whether that happened within timeout. Meant for tests orchestrating readers.
+func (m *LoggedSyncRWMutex) WaitReaders(k int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		m.mu.Lock()
+		n := uint64(m.rLockedCount)
+		m.mu.Unlock()
+		if n == uint64(k) {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(maxPollInterval)
+	}
+}
+
+// ResyncLive overwrites the active lock and read lock counts, e.g. to recover
+// from corrupted counters in tests. The totals are not touched.
+// It does not lock or unlock anything and always writes a warning.
+func (m *LoggedSyncRWMutex) ResyncLive(locked, rlocked uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.warnf(SeverityWarn, "resync", "live counts reset from locked=%d rLocked=%d to locked=%d rLocked=%d", m.lockedCount, m.rLockedCount, locked, rlocked)
+	m.lockedCount, m.rLockedCount = counter(locked), counter(rlocked)
+	m.gcSync()
+}
+
+// LeakAction is what CheckLeaks does when it finds held mutexes.
+type LeakAction int8
+
+const (
+	LeakReport LeakAction = iota // write a warning per held mutex to WarnOutput
+	LeakPanic                    // panic with a *MisuseError naming all held mutexes, e.g. to fail CI
+)
+
+// OnLeak selects the LeakAction of CheckLeaks, defaults to LeakReport.
+var OnLeak = LeakReport
+
+// CheckLeaks checks every registered mutex for active locks or read locks,
+// e.g. at the end of a test run, and returns the number of held mutexes.
+// It reports them as set by OnLeak.
+func CheckLeaks() int {
+	var leaked []string
+	for _, m := range registered() {
+		m.mu.Lock()
+		if m.lockedCount > 0 || m.rLockedCount > 0 {
+			leaked = append(leaked, fmt.Sprintf("'%s' locked=%d rLocked=%d", m.logName(), m.lockedCount, m.rLockedCount))
+			if OnLeak == LeakReport {
+				m.warnf(SeverityWarn, "leak", "still held: locked=%d rLocked=%d", m.lockedCount, m.rLockedCount)
+			}
+		}
+		m.mu.Unlock()
+	}
+	if len(leaked) > 0 && OnLeak == LeakPanic {
+		panic(&MisuseError{Kind: MisuseLeak, Msg: fmt.Sprintf("%d held: %s", len(leaked), strings.Join(leaked, ", "))})
+	}
+	return len(leaked)
+}
@@ -0,0 +1,223 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckBalanced(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestCheckBalanced"}
+
+	err := mux.CheckBalanced(func() {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RLock()
+		mux.RUnlock()
+		mux.RUnlock()
+	})
+	if err != nil {
+		t.Errorf("balanced fn should return nil, got %v", err)
+	}
+
+	err = mux.CheckBalanced(func() {
+		mux.Lock()
+	})
+	if e, ok := err.(*MisuseError); !ok || e.Kind != MisuseUnbalanced || err.Error() != "loggedrwmutex: 'TestCheckBalanced' unbalanced: 1 Lock vs 0 Unlock" {
+		t.Errorf("leaked Lock should return an unbalanced MisuseError, got %v", err)
+	}
+	mux.Unlock()
+
+	err = mux.CheckBalanced(func() {
+		mux.RLock()
+		mux.RLock()
+		mux.RUnlock()
+	})
+	if err == nil {
+		t.Error("leaked RLock should return an error")
+	}
+	mux.RUnlock()
+}
+
+func TestCheckInvariants(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestCheckInvariants", DebugInvariants: true}
+	PanicOnMisuse = true
+	defer func() { PanicOnMisuse = false }()
+
+	// waiting writers and readers are not counted as holders
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mux.Lock()
+				mux.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mux.RLock()
+				mux.RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if err := mux.CheckInvariants(); err != nil {
+		t.Fatalf("CheckInvariants should pass, got %v", err)
+	}
+
+	mux.mu.Lock()
+	mux.totalUnlocked = mux.totalLocked + 2
+	mux.mu.Unlock()
+	if err := mux.CheckInvariants(); err == nil {
+		t.Error("CheckInvariants should fail with more unlocks than locks")
+	}
+	mustPanic(t, "Lock with corrupted counters", mux.Lock)
+	mux.RWMutex.Unlock()
+
+	mux.mu.Lock()
+	mux.totalUnlocked = mux.totalLocked
+	mux.lockedCount = 1
+	mux.mu.Unlock()
+	if err := mux.CheckInvariants(); err != nil {
+		t.Errorf("CheckInvariants should pass with one write lock, got %v", err)
+	}
+	mux.mu.Lock()
+	mux.lockedCount = 2
+	mux.mu.Unlock()
+	if err := mux.CheckInvariants(); err == nil {
+		t.Error("CheckInvariants should fail with two write locks")
+	}
+}
+
+func TestWaitReaders(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestWaitReaders"}
+	if !mux.WaitReaders(0, 0) {
+		t.Error("WaitReaders(0) should succeed on an idle mutex")
+	}
+	if mux.WaitReaders(1, 5*time.Millisecond) {
+		t.Error("WaitReaders(1) should time out without readers")
+	}
+
+	const k = 4
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < k; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mux.RLock()
+			<-release
+			mux.RUnlock()
+		}()
+	}
+	if !mux.WaitReaders(k, time.Second) {
+		t.Fatalf("WaitReaders(%d) should succeed once all readers are in, got %d readers", k, mux.Snapshot().RLocked)
+	}
+	if mux.WaitReaders(k+1, 5*time.Millisecond) {
+		t.Errorf("WaitReaders(%d) should time out", k+1)
+	}
+	close(release)
+	wg.Wait()
+	if !mux.WaitReaders(0, time.Second) {
+		t.Error("WaitReaders(0) should succeed after all readers left")
+	}
+}
+
+func TestStrictInvariants(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestStrictInvariants", StrictInvariants: true}
+	mux.Lock()
+	mux.Unlock()
+	if buf.Len() != 0 {
+		t.Fatalf("exclusive write locks should not warn, got %q", buf.String())
+	}
+
+	// a lost Unlock in the counters
+	mux.mu.Lock()
+	mux.lockedCount = 1
+	mux.mu.Unlock()
+	mux.Lock()
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "'TestStrictInvariants' invariant: write lock acquired with locked=1") {
+		t.Errorf("should warn about the corrupt counters, got %q", got)
+	}
+	mux.Unlock()
+
+	PanicOnMisuse = true
+	defer func() { PanicOnMisuse = false }()
+	mustPanic(t, "Lock with corrupt counters", mux.Lock)
+	mux.RWMutex.Unlock() // the panic happened with the embedded lock held
+}
+
+func TestResyncLive(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestResyncLive"}
+	mux.Lock()
+	mux.Unlock()
+	mux.mu.Lock()
+	mux.lockedCount = 100
+	mux.mu.Unlock()
+
+	mux.ResyncLive(0, 0)
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "'TestResyncLive' resync: live counts reset from locked=100 rLocked=0 to locked=0 rLocked=0") {
+		t.Errorf("ResyncLive should warn, got %q", got)
+	}
+	mux.RLock()
+	if st := mux.Snapshot(); st.Locked != 0 || st.RLocked != 1 || mux.State() != StateReadOnly {
+		t.Errorf("status should be read locked only, got %+v", st)
+	}
+	mux.RUnlock()
+	if st := mux.Snapshot(); st.Locked != 0 || st.RLocked != 0 || st.TotalLocked != 1 || st.TotalUnlocked != 1 || st.TotalRLocked != 1 {
+		t.Errorf("totals should be kept, got %+v", st)
+	}
+	if err := mux.CheckInvariants(); err != nil {
+		t.Errorf("CheckInvariants should pass after ResyncLive, got %v", err)
+	}
+}
+
+func TestCheckLeaks(t *testing.T) {
+	useRegistry(t)
+	buf := captureWarnings(t)
+	a := Register(&LoggedSyncRWMutex{Name: "TestCheckLeaksA"})
+	b := Register(&LoggedSyncRWMutex{Name: "TestCheckLeaksB"})
+	Register(&LoggedSyncRWMutex{Name: "TestCheckLeaksFree"})
+
+	if n := CheckLeaks(); n != 0 || buf.Len() != 0 {
+		t.Fatalf("CheckLeaks should find nothing, got %d %q", n, buf.String())
+	}
+
+	a.Lock()
+	b.RLock()
+	b.RLock()
+	defer func() {
+		a.Unlock()
+		b.RUnlock()
+		b.RUnlock()
+	}()
+	if n := CheckLeaks(); n != 2 {
+		t.Errorf("CheckLeaks should return 2, got %d", n)
+	}
+	want := []string{
+		"[loggedMUTEX] WARN 'TestCheckLeaksA' leak: still held: locked=1 rLocked=0",
+		"[loggedMUTEX] WARN 'TestCheckLeaksB' leak: still held: locked=0 rLocked=2",
+	}
+	if got := lines(buf); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CheckLeaks should report %q, got %q", want, got)
+	}
+
+	OnLeak = LeakPanic
+	defer func() { OnLeak = LeakReport }()
+	buf.Reset()
+	p := recovered(func() { CheckLeaks() })
+	wantPanic := "loggedrwmutex: leak: 2 held: 'TestCheckLeaksA' locked=1 rLocked=0, 'TestCheckLeaksB' locked=0 rLocked=2"
+	if e, ok := p.(*MisuseError); !ok || e.Kind != MisuseLeak || e.Error() != wantPanic {
+		t.Errorf("CheckLeaks should panic with %q, got %v", wantPanic, p)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("LeakPanic should not write warnings, got %q", buf.String())
+	}
+}
@@ -0,0 +1,110 @@
+package loggedrwmutex
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type contextKey struct {
+	name string
+}
+
+// CorrelationKey is the context key LockCtx and RLockCtx look up
+// to include a correlation id in their log lines:
+//
+//	ctx = context.WithValue(ctx, loggedrwmutex.CorrelationKey, "req-42")
+//	if err := mux.LockCtx(ctx); err != nil { ... }
+var CorrelationKey = &contextKey{"correlation-id"}
+
+// maxPollInterval caps the backoff between TryLock attempts while waiting.
+const maxPollInterval = time.Millisecond
+
+// LockCtx locks the mutex like Lock, but gives up and returns ctx.Err() when ctx is done.
+// If ctx carries a value under CorrelationKey it is added to the log line as id=<value>.
+// Counters are only updated once the lock has been acquired.
+func (m *LoggedSyncRWMutex) LockCtx(ctx context.Context) error {
+	st := m.prepare(true)
+	st.id = ctx.Value(CorrelationKey)
+	if err := m.waitCtx(ctx, &st, m.RWMutex.TryLock); err != nil {
+		m.abandoned(st, true)
+		return err
+	}
+	m.acquired(st, true)
+	return nil
+}
+
+// RLockCtx acquires a read lock like RLock, but gives up and returns ctx.Err() when ctx is done.
+// If ctx carries a value under CorrelationKey it is added to the log line as id=<value>.
+// Counters are only updated once the read lock has been acquired.
+func (m *LoggedSyncRWMutex) RLockCtx(ctx context.Context) error {
+	st := m.prepare(false)
+	st.id = ctx.Value(CorrelationKey)
+	if err := m.waitCtx(ctx, &st, m.RWMutex.TryRLock); err != nil {
+		m.abandoned(st, false)
+		return err
+	}
+	m.acquired(st, false)
+	return nil
+}
+
+// LockTimeout tries to lock the mutex within d and reports whether it succeeded.
+// Counters are only updated on success.
+func (m *LoggedSyncRWMutex) LockTimeout(d time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	return m.LockCtx(ctx) == nil
+}
+
+// waitCtx is acquireCtx, labeled for ProfileLabels on top of the labels of ctx.
+func (m *LoggedSyncRWMutex) waitCtx(ctx context.Context, st *acquireState, try func() bool) (err error) {
+	if !st.profile {
+		return acquireCtx(ctx, st, try)
+	}
+	acquireLabeled(ctx, st.labelName, func() { err = acquireCtx(ctx, st, try) })
+	return err
+}
+
+// acquireCtx polls try with a growing backoff until it succeeds or ctx is done.
+// With ContentionTryFirst it records in st whether the first try found the lock free,
+// st may be nil if that has already been recorded.
+func acquireCtx(ctx context.Context, st *acquireState, try func() bool) error {
+	wait := time.Microsecond
+	for first := st != nil && st.tryFirst; ; first = false {
+		if try() {
+			if first {
+				st.uncontended = true
+			}
+			return nil
+		}
+		if first {
+			st.contended = true
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if wait < maxPollInterval {
+			wait *= 2
+		}
+	}
+}
+
+// idField formats an optional correlation id as log field.
+func idField(id any) string {
+	if id == nil {
+		return ""
+	}
+	return fmt.Sprintf("id=%v", id)
+}
+
+// labelField formats an optional LockLabeled label as log field.
+func labelField(label string) string {
+	if label == "" {
+		return ""
+	}
+	return "(" + label + ")"
+}
@@ -0,0 +1,95 @@
+package loggedrwmutex
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockCtxCorrelationID(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestLockCtx", DebugLock: DebugOn, DebugRLock: DebugOn}
+	ctx := context.WithValue(context.Background(), CorrelationKey, "req-42")
+
+	if err := mux.LockCtx(ctx); err != nil {
+		t.Fatalf("LockCtx failed: %v", err)
+	}
+	mux.Unlock()
+	if err := mux.RLockCtx(ctx); err != nil {
+		t.Fatalf("RLockCtx failed: %v", err)
+	}
+	mux.RUnlock()
+
+	got := lines(buf)
+	if len(got) != 2 {
+		t.Fatalf("should log 2 lines, got %d: %q", len(got), got)
+	}
+	for _, line := range got {
+		if !strings.Contains(line, "id=req-42") {
+			t.Errorf("log line should contain the correlation id: %q", line)
+		}
+	}
+
+	// no correlation id in the context
+	buf.Reset()
+	if err := mux.LockCtx(context.Background()); err != nil {
+		t.Fatalf("LockCtx failed: %v", err)
+	}
+	mux.Unlock()
+	if strings.Contains(buf.String(), "id=") {
+		t.Errorf("log line should not contain an id: %q", buf.String())
+	}
+}
+
+func TestLockCtxCancel(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestLockCtxCancel"}
+	mux.Lock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := mux.LockCtx(ctx); err != context.DeadlineExceeded {
+		t.Errorf("LockCtx should return DeadlineExceeded, got %v", err)
+	}
+	if err := mux.RLockCtx(ctx); err != context.DeadlineExceeded {
+		t.Errorf("RLockCtx should return DeadlineExceeded, got %v", err)
+	}
+	if mux.totalLocked != 1 || mux.totalrLocked != 0 {
+		t.Errorf("failed attempts should not be counted, got totalLocked=%d totalrLocked=%d", mux.totalLocked, mux.totalrLocked)
+	}
+
+	// a lock released while waiting is acquired
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mux.Unlock()
+	}()
+	if err := mux.LockCtx(context.Background()); err != nil {
+		t.Errorf("LockCtx should acquire once released, got %v", err)
+	}
+	mux.Unlock()
+}
+
+func TestLockTimeout(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestLockTimeout"}
+	mux.Lock()
+
+	start := time.Now()
+	if mux.LockTimeout(20 * time.Millisecond) {
+		t.Fatal("LockTimeout should fail while the lock is held")
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("LockTimeout should wait the duration, returned after %v", waited)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 1 {
+		t.Errorf("failed LockTimeout should not be counted, got totalLocked=%d", st.TotalLocked)
+	}
+
+	mux.Unlock()
+	if !mux.LockTimeout(20 * time.Millisecond) {
+		t.Fatal("LockTimeout should succeed once the lock is free")
+	}
+	if st := mux.Snapshot(); st.Locked != 1 || st.TotalLocked != 2 {
+		t.Errorf("LockTimeout should be counted, got %+v", st)
+	}
+	mux.Unlock()
+}
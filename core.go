@@ -0,0 +1,55 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// lockCore is the state LoggedSyncRWMutex and LoggedSyncMutex share: the internal
+// mutex, the write lock counters and the state of the log output. Together with
+// opLine it is what lets both types count and log alike.
+type lockCore struct {
+	mu              sync.Mutex // internal mutex to protect the state of the mutex, not the embedded lock
+	lockedCount     counter    // number of active locks
+	writerWaiters   counter    // goroutines in Lock that have not acquired yet, see RLockPolite
+	totalLocked     counter
+	totalUnlocked   counter
+	seq             uint64  // sequence number of the last recorded event
+	writeErrorCount counter // failed writes of log lines
+	writeDisabled   bool    // logging stopped after MaxWriteErrors
+}
+
+// lockedField formats the active write locks and total as log field.
+// Must be called with c.mu held.
+func (c *lockCore) lockedField(total counter) string {
+	return fmt.Sprintf("locked=%d/%d", c.lockedCount, total)
+}
+
+// writef writes one log line to w unless logging is paused or has been stopped
+// after MaxWriteErrors failed writes, and returns the error of the write.
+// Must be called with c.mu held.
+func (c *lockCore) writef(w io.Writer, format string, args ...any) error {
+	if loggingPaused.Load() || c.writeDisabled {
+		return nil
+	}
+	if RelativeTimestamps {
+		format = fmt.Sprintf("+%dms ", now().Sub(processStart).Milliseconds()) + format
+	}
+	return safeFprintf(w, format, args...)
+}
+
+// writeFailed counts a failed write of a log line and stops logging after MaxWriteErrors.
+// It returns the message of the warning to write when logging has been stopped, "" otherwise.
+// Must be called with c.mu held.
+func (c *lockCore) writeFailed(err error) string {
+	if err == nil {
+		return ""
+	}
+	c.writeErrorCount++
+	if MaxWriteErrors == 0 || uint64(c.writeErrorCount) < MaxWriteErrors {
+		return ""
+	}
+	c.writeDisabled = true
+	return fmt.Sprintf("%d failed writes, logging disabled: %v", c.writeErrorCount, err)
+}
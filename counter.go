@@ -0,0 +1,6 @@
+//go:build !loggedmutex_uint32
+
+package loggedrwmutex
+
+// counter is the type of the lock and event counters, uint32 with the loggedmutex_uint32 build tag.
+type counter = uint64
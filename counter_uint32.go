@@ -0,0 +1,13 @@
+//go:build loggedmutex_uint32
+
+package loggedrwmutex
+
+// counter is the type of the lock and event counters. The loggedmutex_uint32
+// build tag narrows them to 32 bits for builds with many mutexes on memory
+// constrained devices. The state of the debugging features is allocated
+// separately on first use and keeps its size, so on 64-bit platforms a
+// LoggedSyncRWMutex without features shrinks from 456 to 400 bytes and a
+// LoggedSyncMutex from 112 to 88 bytes.
+// The counters wrap around after 4294967295 operations, the accessors still
+// return uint64 values.
+type counter = uint32
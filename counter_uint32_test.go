@@ -0,0 +1,51 @@
+//go:build loggedmutex_uint32
+
+package loggedrwmutex
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+)
+
+func TestCounterUint32(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestCounterUint32"}
+	if size := unsafe.Sizeof(mux.totalLocked); size != 4 {
+		t.Errorf("counters should be 4 bytes, got %d", size)
+	}
+	if size := unsafe.Sizeof(features{}.contendedCount); size != 4 {
+		t.Errorf("event counters should be 4 bytes, got %d", size)
+	}
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	if st := mux.Snapshot(); st.TotalLocked != 1 || st.TotalUnlocked != 1 || st.TotalRLocked != 1 || st.TotalRUnlocked != 1 {
+		t.Errorf("should count with uint32 counters, got %+v", st)
+	}
+
+	mux.mu.Lock()
+	mux.totalLocked, mux.totalUnlocked = math.MaxUint32, math.MaxUint32
+	mux.mu.Unlock()
+	mux.Lock()
+	if st := mux.Snapshot(); st.TotalLocked != 0 || st.Locked != 1 {
+		t.Errorf("TotalLocked should wrap to 0 after MaxUint32, got %+v", st)
+	}
+	mux.Unlock()
+	if st := mux.Snapshot(); st.TotalUnlocked != 0 || st.Locked != 0 {
+		t.Errorf("TotalUnlocked should wrap to 0 after MaxUint32, got %+v", st)
+	}
+}
+
+func TestCounterUint32Sum(t *testing.T) {
+	beats := 0
+	mux := &LoggedSyncRWMutex{Name: "TestCounterUint32Sum", HeartbeatEvery: 3, OnHeartbeat: func(Stats) { beats++ }}
+	mux.mu.Lock()
+	mux.totalLocked, mux.totalUnlocked, mux.totalrLocked = math.MaxUint32-1, math.MaxUint32-1, 1
+	mux.mu.Unlock()
+	mux.Lock() // 4294967295 + 1 acquisitions, 0 if summed in 32 bits
+	mux.Unlock()
+	if beats != 0 {
+		t.Errorf("acquisitions should be summed in 64 bits, got %d heartbeats", beats)
+	}
+}
@@ -0,0 +1,91 @@
+package loggedrwmutex
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// DeadlockAction is what happens when an acquisition waits longer than DeadlockTimeout.
+type DeadlockAction int8
+
+const (
+	DeadlockWarn  DeadlockAction = iota // write a warning to WarnOutput and keep waiting
+	DeadlockPanic                       // panic in the waiting goroutine with a dump of all goroutines
+	DeadlockExit                        // write a dump of all goroutines to WarnOutput and exit with code 2
+)
+
+// deadlockExitCode is the exit code of DeadlockExit.
+const deadlockExitCode = 2
+
+// acquireSlow acquires the embedded lock with ContentionTryFirst, DeadlockTimeout or ProfileLabels.
+// Without DeadlockTimeout it blocks in Lock or RLock, with DeadlockTimeout it runs
+// OnDeadlock once the wait exceeds the timeout. Up to the timeout it polls TryLock
+// or TryRLock, so during that time a waiting Lock does not keep new readers out.
+func (m *LoggedSyncRWMutex) acquireSlow(st *acquireState, write bool) {
+	if st.profile {
+		st.profile = false
+		acquireLabeled(context.Background(), st.labelName, func() { m.acquireSlow(st, write) })
+		return
+	}
+	try, block := m.RWMutex.TryRLock, m.RWMutex.RLock
+	if write {
+		try, block = m.RWMutex.TryLock, m.RWMutex.Lock
+	}
+	if st.tryFirst {
+		if try() {
+			st.uncontended = true
+			return
+		}
+		st.contended = true
+	}
+	if st.deadlockTimeout <= 0 {
+		block()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), st.deadlockTimeout)
+	defer cancel()
+	if acquireCtx(ctx, nil, try) == nil {
+		return
+	}
+	m.deadlockSuspected(*st, write)
+	block()
+}
+
+// deadlockSuspected reports an acquisition waiting longer than DeadlockTimeout.
+func (m *LoggedSyncRWMutex) deadlockSuspected(st acquireState, write bool) {
+	op := "RLock"
+	if write {
+		op = "Lock"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	msg := fmt.Sprintf("%s waiting longer than %v, possible deadlock", op, st.deadlockTimeout)
+	f := m.view()
+	if f.writeOwner != 0 {
+		msg += fmt.Sprintf(", write lock held by goroutine %d", f.writeOwner)
+	}
+	switch st.deadlockAction {
+	case DeadlockPanic:
+		m.waitEnded(write)
+		panic(fmt.Sprintf("[loggedMUTEX] '%s' deadlock: %s\n%s", m.logName(), msg, allStacks()))
+	case DeadlockExit:
+		fmt.Fprintf(WarnOutput, "[loggedMUTEX] '%s' deadlock: %s\n%s", m.logName(), msg, allStacks())
+		os.Exit(deadlockExitCode)
+	default:
+		m.warnf(SeverityCritical, "deadlock", "%s", msg)
+	}
+}
+
+// allStacks returns the stacks of all goroutines.
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,120 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadlockWarn(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDeadlockWarn", TrackOwnership: true, DeadlockTimeout: 10 * time.Millisecond}
+	mux.Lock()
+	holder := goid()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	got := lines(buf)
+	want := fmt.Sprintf("deadlock: RLock waiting longer than 10ms, possible deadlock, write lock held by goroutine %d", holder)
+	if len(got) != 1 || !strings.Contains(got[0], want) {
+		t.Errorf("should warn once with %q, got %q", want, got)
+	}
+	if st := mux.Snapshot(); st.TotalRLocked != 1 || st.RLocked != 0 {
+		t.Errorf("RLock should succeed after the warning, got %+v", st)
+	}
+}
+
+func TestDeadlockPanic(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestDeadlockPanic", DeadlockTimeout: 10 * time.Millisecond, OnDeadlock: DeadlockPanic}
+	mux.Lock()
+	defer mux.Unlock()
+
+	p := recovered(mux.Lock) // would never return
+	msg, _ := p.(string)
+	if !strings.HasPrefix(msg, "[loggedMUTEX] 'TestDeadlockPanic' deadlock: Lock waiting longer than 10ms") {
+		t.Fatalf("Lock should panic about the deadlock, got %v", p)
+	}
+	if !strings.Contains(msg, "goroutine ") || !strings.Contains(msg, "TestDeadlockPanic") {
+		t.Errorf("panic should contain the goroutine stacks, got %q", msg)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 1 {
+		t.Errorf("panicked Lock should not be counted, got %+v", st)
+	}
+}
+
+func TestDeadlockExit(t *testing.T) {
+	if os.Getenv("LOGGEDRWMUTEX_DEADLOCK_EXIT") == "1" {
+		mux := &LoggedSyncRWMutex{Name: "TestDeadlockExit", DeadlockTimeout: 10 * time.Millisecond, OnDeadlock: DeadlockExit}
+		mux.Lock()
+		mux.Lock()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDeadlockExit$")
+	cmd.Env = append(os.Environ(), "LOGGEDRWMUTEX_DEADLOCK_EXIT=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != deadlockExitCode {
+		t.Fatalf("process should exit with code %d, got %v", deadlockExitCode, err)
+	}
+	if out := stderr.String(); !strings.Contains(out, "'TestDeadlockExit' deadlock: Lock waiting longer than 10ms") || !strings.Contains(out, "goroutine ") {
+		t.Errorf("stderr should contain the deadlock and the goroutine dump, got %q", out)
+	}
+}
+
+func TestDeadlockLockWeighted(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDeadlockLockWeighted", DeadlockTimeout: 10 * time.Millisecond}
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.LockWeighted(3)
+		mux.Unlock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "deadlock: Lock waiting longer than 10ms, possible deadlock") {
+		t.Errorf("LockWeighted should warn about the deadlock, got %q", got)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 2 || st.TotalWeight != 4 {
+		t.Errorf("LockWeighted should succeed after the warning, got %+v", st)
+	}
+}
+
+func TestDeadlockLockLabeled(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDeadlockLockLabeled", DeadlockTimeout: 10 * time.Millisecond}
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.LockLabeled("flush")
+		mux.Unlock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "deadlock: Lock waiting longer than 10ms, possible deadlock") {
+		t.Errorf("LockLabeled should warn about the deadlock, got %q", got)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 2 {
+		t.Errorf("LockLabeled should succeed after the warning, got %+v", st)
+	}
+}
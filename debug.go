@@ -0,0 +1,60 @@
+package loggedrwmutex
+
+import (
+	"time"
+)
+
+// DebugFlag is the debug setting of a single operation.
+type DebugFlag int8
+
+const (
+	DebugDefault DebugFlag = iota // follows DebugAll and GlobalDebug
+	DebugOn                       // always print debug messages for the operation
+	DebugOff                      // never print debug messages for the operation, even with DebugAll or GlobalDebug
+)
+
+// debug reports whether debug messages are enabled for an operation with the flag f.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) debug(f DebugFlag) bool {
+	return debugEnabled(f, m.DebugAll)
+}
+
+// debugEnabled reports whether debug messages are enabled for an operation
+// with the flag f of a mutex with the DebugAll setting all.
+func debugEnabled(f DebugFlag, all bool) bool {
+	switch f {
+	case DebugOn:
+		return true
+	case DebugOff:
+		return false
+	}
+	return all || GlobalDebug
+}
+
+// SetDebugAll sets DebugAll while the mutex may be in use by other goroutines.
+func (m *LoggedSyncRWMutex) SetDebugAll(on bool) {
+	m.mu.Lock()
+	m.DebugAll = on
+	m.mu.Unlock()
+}
+
+// DebugFor enables DebugAll for the duration d and restores the previous
+// DebugAll state afterwards. Overlapping windows extend each other: the state
+// from before the first window is restored when the last one expires.
+func (m *LoggedSyncRWMutex) DebugFor(d time.Duration) {
+	m.mu.Lock()
+	if m.debugWindows == 0 {
+		m.debugPrev = m.DebugAll
+	}
+	m.debugWindows++
+	m.DebugAll = true
+	m.mu.Unlock()
+	time.AfterFunc(d, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.debugWindows--
+		if m.debugWindows == 0 {
+			m.DebugAll = m.debugPrev
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugFor(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDebugFor"}
+
+	mux.DebugFor(50 * time.Millisecond)
+	mux.Lock()
+	mux.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	mux.Lock()
+	mux.Unlock()
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if got := lines(buf); len(got) != 2 {
+		t.Errorf("should only log during DebugFor, got %q", got)
+	}
+	if mux.DebugAll {
+		t.Error("DebugAll should be restored after DebugFor")
+	}
+}
+
+func TestDebugForOverlap(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestDebugForOverlap"}
+	debugAll := func() bool {
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+		return mux.DebugAll
+	}
+
+	mux.DebugFor(20 * time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	mux.DebugFor(20 * time.Millisecond)
+	time.Sleep(15 * time.Millisecond) // the first window has expired
+	if !debugAll() {
+		t.Error("DebugAll should stay on until the last window expires")
+	}
+	time.Sleep(35 * time.Millisecond)
+	if debugAll() {
+		t.Error("DebugAll should be restored after overlapping DebugFor windows")
+	}
+
+	// a DebugAll set before is restored as well
+	mux.SetDebugAll(true)
+	mux.DebugFor(5 * time.Millisecond)
+	mux.DebugFor(10 * time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	if !debugAll() {
+		t.Error("DebugAll should be restored to true")
+	}
+}
+
+func TestDebugWaiting(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDebugWaiting", DebugAll: true}
+	logged := func() []string {
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+		return lines(buf)
+	}
+
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	for i := 0; i < 100 && len(logged()) < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	mux.Unlock()
+	<-done
+
+	want := []string{
+		"[loggedMUTEX] Lock 'TestDebugWaiting' locked=1/1",
+		"[loggedMUTEX] RLock 'TestDebugWaiting' waiting locked=1 rLocked=0",
+		"[loggedMUTEX] Unlock 'TestDebugWaiting' locked=0/1",
+		"[loggedMUTEX] RLock 'TestDebugWaiting' rLocked=1/1",
+		"[loggedMUTEX] RUnlock 'TestDebugWaiting' rLockedCount=0/1",
+	}
+	if got := logged(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("a blocked RLock should log before and after acquiring, want %q, got %q", want, got)
+	}
+}
+
+func TestDebugFlagOff(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDebugFlagOff", DebugAll: true, DebugRUnlock: DebugOff}
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+
+	got := lines(buf)
+	if len(got) != 3 {
+		t.Fatalf("should log 3 lines, got %q", got)
+	}
+	for i, op := range []string{"] Lock ", "] Unlock ", "] RLock "} {
+		if !strings.Contains(got[i], op) {
+			t.Errorf("line %d should be %q, got %q", i, op, got[i])
+		}
+	}
+
+	// DebugOn works without DebugAll
+	buf.Reset()
+	mux = &LoggedSyncRWMutex{Name: "TestDebugFlagOn", DebugRUnlock: DebugOn}
+	mux.RLock()
+	mux.RUnlock()
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "] RUnlock ") {
+		t.Errorf("only RUnlock should be logged, got %q", got)
+	}
+}
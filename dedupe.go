@@ -0,0 +1,44 @@
+package loggedrwmutex
+
+import "regexp"
+
+// dedupeTotals matches the running totals of log lines like the /5 in locked=1/5.
+var dedupeTotals = regexp.MustCompile(`/\d+`)
+
+// dedupe reports whether line repeats one of the last two lines apart from its totals
+// and has to be suppressed, so both a repeated line and a repeated pair such as
+// Lock/Unlock collapse. Otherwise it writes the repeat count of the suppressed lines
+// if any. Either way line becomes the last line.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) dedupe(line string) bool {
+	key := dedupeTotals.ReplaceAllString(line, "/")
+	f := m.feat()
+	repeated := key == f.dedupeLast || key == f.dedupePrev
+	f.dedupePrev, f.dedupeLast = f.dedupeLast, key
+	if repeated {
+		f.dedupeRepeats++
+		return true
+	}
+	m.flushDedupe()
+	return false
+}
+
+// flushDedupe writes the pending repeat count of DedupeConsecutive.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) flushDedupe() {
+	f := m.feat()
+	if f.dedupeRepeats > 0 {
+		m.logf("[loggedMUTEX] '%s' (repeated %d times)\n", m.logName(), f.dedupeRepeats)
+	}
+	f.dedupeRepeats = 0
+}
+
+// FlushDedupe writes the repeat count of lines collapsed by DedupeConsecutive,
+// e.g. before the program exits. The next line is written in full again.
+func (m *LoggedSyncRWMutex) FlushDedupe() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushDedupe()
+	f := m.feat()
+	f.dedupeLast, f.dedupePrev = "", ""
+}
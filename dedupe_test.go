@@ -0,0 +1,79 @@
+package loggedrwmutex
+
+import (
+	"testing"
+)
+
+func TestDedupeConsecutive(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDedupe", DebugLock: DebugOn, DedupeConsecutive: true}
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	mux.RLock()
+	mux.RUnlock()
+	mux.DebugRLock = DebugOn
+	mux.RLock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.RUnlock()
+	mux.FlushDedupe()
+	mux.FlushDedupe() // nothing pending
+
+	want := []string{
+		"[loggedMUTEX] Lock 'TestDedupe' locked=1/1",
+		"[loggedMUTEX] 'TestDedupe' (repeated 99 times)",
+		"[loggedMUTEX] RLock 'TestDedupe' rLocked=1/2",
+		"[loggedMUTEX] RLock 'TestDedupe' rLocked=2/3",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log %d lines, got %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	// after a flush the next line is written in full
+	buf.Reset()
+	mux.Lock()
+	mux.Unlock()
+	mux.Lock()
+	mux.Unlock()
+	mux.FlushDedupe()
+	if got := lines(buf); len(got) != 2 || got[1] != "[loggedMUTEX] 'TestDedupe' (repeated 1 times)" {
+		t.Errorf("should log the line and its repeat count, got %q", got)
+	}
+}
+
+func TestDedupeCycles(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDedupeCycles", DebugAll: true, DedupeConsecutive: true}
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	mux.RLock()
+	mux.RUnlock()
+	mux.FlushDedupe()
+
+	want := []string{
+		"[loggedMUTEX] Lock 'TestDedupeCycles' locked=1/1",
+		"[loggedMUTEX] Unlock 'TestDedupeCycles' locked=0/1",
+		"[loggedMUTEX] 'TestDedupeCycles' (repeated 198 times)",
+		"[loggedMUTEX] RLock 'TestDedupeCycles' rLocked=1/1",
+		"[loggedMUTEX] RUnlock 'TestDedupeCycles' rLockedCount=0/1",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log %d lines, got %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"sync"
+)
+
+// IndentByDepth indents the log lines of every mutex by two spaces per other
+// logged lock the goroutine holds, so nested critical sections stand out.
+// Set it before the first lock, holds taken earlier are not known.
+var IndentByDepth = false
+
+var (
+	depthMu   sync.Mutex
+	depthHeld = map[int64]int{} // goroutine id -> locks and read locks held
+)
+
+// depthAcquired records an acquisition by goroutine gid
+// and returns the number of other locks it holds.
+func depthAcquired(gid int64) int {
+	depthMu.Lock()
+	defer depthMu.Unlock()
+	depthHeld[gid]++
+	return depthHeld[gid] - 1
+}
+
+// depthReleased records a release by goroutine gid
+// and returns the number of other locks it holds.
+func depthReleased(gid int64) int {
+	depthMu.Lock()
+	defer depthMu.Unlock()
+	n := depthHeld[gid] - 1
+	if n <= 0 {
+		delete(depthHeld, gid)
+		return 0
+	}
+	depthHeld[gid] = n
+	return n
+}
+
+// indent returns the prefix of a log line at depth.
+func indent(depth int) string {
+	return strings.Repeat("  ", depth)
+}
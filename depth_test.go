@@ -0,0 +1,58 @@
+package loggedrwmutex
+
+import (
+	"testing"
+)
+
+func TestIndentByDepth(t *testing.T) {
+	buf := captureOutput(t)
+	IndentByDepth = true
+	defer func() { IndentByDepth = false }()
+	outer := &LoggedSyncRWMutex{Name: "TestIndentOuter", DebugAll: true}
+	inner := &LoggedSyncRWMutex{Name: "TestIndentInner", DebugAll: true}
+
+	outer.Lock()
+	inner.RLock()
+	inner.RLock()
+	inner.RUnlock()
+	inner.RUnlock()
+	outer.Unlock()
+
+	want := []string{
+		"[loggedMUTEX] Lock 'TestIndentOuter' locked=1/1",
+		"  [loggedMUTEX] RLock 'TestIndentInner' rLocked=1/1",
+		"    [loggedMUTEX] RLock 'TestIndentInner' rLocked=2/2",
+		"    [loggedMUTEX] RUnlock 'TestIndentInner' rLockedCount=1/1",
+		"  [loggedMUTEX] RUnlock 'TestIndentInner' rLockedCount=0/2",
+		"[loggedMUTEX] Unlock 'TestIndentOuter' locked=0/1",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log %d lines, got %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	// locks of other goroutines do not indent
+	buf.Reset()
+	outer.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inner.Lock()
+		inner.Unlock()
+	}()
+	<-done
+	outer.Unlock()
+	for _, line := range lines(buf) {
+		if line[0] == ' ' {
+			t.Errorf("line of another goroutine should not be indented: %q", line)
+		}
+	}
+	if len(depthHeld) != 0 {
+		t.Errorf("depths should be released, got %v", depthHeld)
+	}
+}
@@ -0,0 +1,78 @@
+package loggedrwmutex
+
+import (
+	"os"
+	"path"
+	"strings"
+)
+
+// DebugEnv is the environment variable read by ConfigureFromEnv,
+// a comma separated list of mutex names.
+const DebugEnv = "LOGGEDMUTEX_DEBUG"
+
+// envDebug holds the names from DebugEnv, protected by registryMu.
+var envDebug map[string]bool
+
+// ConfigureFromEnv enables DebugAll on the registered mutexes named in DebugEnv,
+// e.g. LOGGEDMUTEX_DEBUG=ResourceMutex,OtherMutex, and on mutexes registered
+// later under one of these names. Calling it again replaces the list.
+func ConfigureFromEnv() {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv(DebugEnv), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	envDebug = names
+	for name, m := range registry {
+		if names[name] {
+			m.configureDebug()
+		}
+	}
+}
+
+// debugGlobs holds the patterns of EnableDebugGlob, protected by registryMu.
+var debugGlobs []string
+
+// EnableDebugGlob enables DebugAll on the registered mutexes whose Name matches
+// pattern with the syntax of path.Match, e.g. "db-*", and on mutexes registered later
+// with a matching name. Patterns accumulate, a malformed pattern matches nothing.
+func EnableDebugGlob(pattern string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	debugGlobs = append(debugGlobs, pattern)
+	for name, m := range registry {
+		if globMatch(pattern, name) {
+			m.configureDebug()
+		}
+	}
+}
+
+// debugWanted reports whether name is listed in DebugEnv or matches an EnableDebugGlob pattern.
+// Must be called with registryMu held.
+func debugWanted(name string) bool {
+	if envDebug[name] {
+		return true
+	}
+	for _, pattern := range debugGlobs {
+		if globMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// globMatch reports whether name matches pattern, see path.Match.
+func globMatch(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return ok && err == nil
+}
+
+// configureDebug enables DebugAll for ConfigureFromEnv and EnableDebugGlob.
+func (m *LoggedSyncRWMutex) configureDebug() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.DebugAll = true
+}
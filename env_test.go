@@ -0,0 +1,78 @@
+package loggedrwmutex
+
+import (
+	"testing"
+)
+
+func TestConfigureFromEnv(t *testing.T) {
+	useRegistry(t)
+	buf := captureOutput(t)
+	existing := Register(&LoggedSyncRWMutex{Name: "TestEnvExisting"})
+	other := Register(&LoggedSyncRWMutex{Name: "TestEnvOther"})
+
+	t.Setenv(DebugEnv, "TestEnvExisting, TestEnvLater")
+	ConfigureFromEnv()
+	defer func() {
+		registryMu.Lock()
+		envDebug = nil
+		registryMu.Unlock()
+	}()
+	later := Register(&LoggedSyncRWMutex{Name: "TestEnvLater"})
+
+	for _, m := range []*LoggedSyncRWMutex{existing, other, later} {
+		m.Lock()
+		m.Unlock()
+	}
+	want := []string{
+		"[loggedMUTEX] Lock 'TestEnvExisting' locked=1/1",
+		"[loggedMUTEX] Unlock 'TestEnvExisting' locked=0/1",
+		"[loggedMUTEX] Lock 'TestEnvLater' locked=1/1",
+		"[loggedMUTEX] Unlock 'TestEnvLater' locked=0/1",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("only the listed mutexes should log, got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestEnableDebugGlob(t *testing.T) {
+	useRegistry(t)
+	buf := captureOutput(t)
+	users := Register(&LoggedSyncRWMutex{Name: "db-users"})
+	cache := Register(&LoggedSyncRWMutex{Name: "cache"})
+
+	EnableDebugGlob("db-*")
+	EnableDebugGlob("[") // malformed, matches nothing
+	defer func() {
+		registryMu.Lock()
+		debugGlobs = nil
+		registryMu.Unlock()
+	}()
+	orders := Register(&LoggedSyncRWMutex{Name: "db-orders"})
+	other := Register(&LoggedSyncRWMutex{Name: "dbx"})
+
+	for _, m := range []*LoggedSyncRWMutex{users, cache, orders, other} {
+		m.Lock()
+		m.Unlock()
+	}
+	want := []string{
+		"[loggedMUTEX] Lock 'db-users' locked=1/1",
+		"[loggedMUTEX] Unlock 'db-users' locked=0/1",
+		"[loggedMUTEX] Lock 'db-orders' locked=1/1",
+		"[loggedMUTEX] Unlock 'db-orders' locked=0/1",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("only the matching mutexes should log, got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+}
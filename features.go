@@ -0,0 +1,97 @@
+package loggedrwmutex
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// features is the state of the debugging features of a mutex. Most mutexes use
+// none of them, so it is allocated by the first feature that needs it and a
+// LoggedSyncRWMutex without any stays small.
+type features struct {
+	// ownership tracking and CountGoroutines
+	writeOwner        int64               // goroutine id holding the write lock, 0 if none
+	writeSince        time.Time           // acquisition of the write lock by writeOwner
+	readOwners        map[int64]int       // goroutine id -> read lock depth
+	readSince         map[int64]time.Time // goroutine id -> acquisition of the outermost read lock
+	goroutines        map[int64]struct{}  // goroutines seen with CountGoroutines
+	trackingEvictions counter             // entries evicted from tracking maps, see MaxTrackedEntries
+
+	// RecordUse
+	firstUse time.Time
+	lastUse  time.Time
+
+	// MeasureContention
+	waitTotal        time.Duration
+	waitCount        counter
+	waitMax          time.Duration
+	worstWaiter      Waiter  // acquisition that waited waitMax
+	contendedCount   counter // acquisitions that found the lock taken with ContentionTryFirst
+	uncontendedCount counter // acquisitions that got the lock with the first TryLock of ContentionTryFirst
+
+	// MeasureHold
+	holdSampleSeq    uint64
+	holdTotal        time.Duration
+	holdCount        counter
+	holdStart        time.Time     // acquisition time of the write lock
+	rHoldStarts      []time.Time   // acquisition times of active read locks, oldest first
+	holdHist         *histogram    // hold times, see SetLatencyBuckets
+	intervalHist     *histogram    // hold times since the last check of StartSlowHoldAlert, nil if none runs
+	measureStart     time.Time     // first measured acquisition
+	busyStart        time.Time     // start of the current held period, zero if free
+	busyTotal        time.Duration // accumulated time held by anyone
+	unlockTimeTotal  time.Duration // time spent in the embedded Unlock and RUnlock
+	unlockTimeMax    time.Duration
+	trivialHoldCount counter
+
+	// CompareBaseline
+	baseline        sync.RWMutex  // plain mutex for CompareBaseline
+	baselineLocked  bool          // baseline is locked for writing
+	baselineReaders int           // baseline read locks
+	overhead        time.Duration // accumulated overhead against the baseline
+
+	// EnableBinaryTrace and HistorySize
+	binaryTrace io.Writer // if set, receives binary event records
+	history     []Event   // ring buffer of retained events
+	historyNext int       // next write position in history
+
+	// PairedLogging
+	pairedWait   time.Duration   // wait of the write lock for PairedLogging, -1 if not timed
+	pairedRWaits []time.Duration // waits of active read locks, oldest first
+
+	// log line options
+	lastLogged     [opRUnlock + 1]time.Time // last line per op for LogGaps
+	dedupeLast     string                   // dedupe key of the last line
+	dedupePrev     string                   // dedupe key of the line before dedupeLast
+	dedupeRepeats  counter                  // lines suppressed since the last written line
+	rateStart      time.Time                // start of the current AutoDisableRate window
+	rateLines      int                      // log lines in the current window
+	autoDisabledAt time.Time                // when AutoDisableRate disabled logging, zero if enabled
+
+	callerSamples map[string]uint64     // folded stack -> samples of SampleCallersEvery
+	warnings      map[string]*warnState // last emission per warning kind for WarnSuppressWindow
+	gc            *gcWatch              // state of DetectGCWhileHeld
+}
+
+// noFeatures is what the accessors of a mutex without features read.
+var noFeatures features
+
+// feat returns the features of m, allocating them on first use.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) feat() *features {
+	if m.ext == nil {
+		m.ext = &features{}
+	}
+	return m.ext
+}
+
+// view returns the features of m for reading, the zero features if none
+// have been used. The result must not be modified.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) view() *features {
+	if m.ext == nil {
+		return &noFeatures
+	}
+	return m.ext
+}
@@ -0,0 +1,25 @@
+package loggedrwmutex
+
+import "testing"
+
+func TestFeaturesLazy(t *testing.T) {
+	captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestFeaturesLazy", DebugAll: true}
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.Snapshot()
+	mux.AvgHold()
+	mux.HolderGoroutine()
+	if mux.ext != nil {
+		t.Fatal("a mutex without features should not allocate their state")
+	}
+
+	mux.MeasureHold = true
+	mux.Lock()
+	mux.Unlock()
+	if mux.ext == nil {
+		t.Fatal("MeasureHold should allocate the feature state")
+	}
+}
@@ -0,0 +1,52 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// gcWatch is the state of DetectGCWhileHeld. It is allocated apart from the
+// mutex, so the cleanup can still read it once the mutex has been collected.
+type gcWatch struct {
+	mu      sync.Mutex
+	name    string // logName of the mutex
+	json    bool   // JSONLines of the mutex
+	locked  counter
+	rLocked counter
+}
+
+// watchGC adds the cleanup of DetectGCWhileHeld once. runtime.AddCleanup accepts
+// interior pointers, so the mutex may be a field of another struct.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) watchGC() {
+	f := m.feat()
+	if f.gc != nil {
+		return
+	}
+	f.gc = &gcWatch{}
+	m.gcSync()
+	runtime.AddCleanup(m, (*gcWatch).collected, f.gc)
+}
+
+// gcSync copies what the DetectGCWhileHeld warning needs to its gcWatch, if watched.
+// Must be called with m.mu held after the live counts or the name changed.
+func (m *LoggedSyncRWMutex) gcSync() {
+	f := m.view()
+	if f.gc == nil {
+		return
+	}
+	f.gc.mu.Lock()
+	f.gc.name, f.gc.json = m.logName(), m.JSONLines
+	f.gc.locked, f.gc.rLocked = m.lockedCount, m.rLockedCount
+	f.gc.mu.Unlock()
+}
+
+// collected is the cleanup of DetectGCWhileHeld, it warns if the mutex was still held.
+func (w *gcWatch) collected() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if (w.locked > 0 || w.rLocked > 0) && SeverityCritical >= MinWarnSeverity {
+		writeWarning(w.json, w.name, SeverityCritical, "gc-held", fmt.Sprintf("GC'd while held locked=%d rLocked=%d", w.locked, w.rLocked), now())
+	}
+}
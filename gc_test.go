@@ -0,0 +1,69 @@
+package loggedrwmutex
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectGCWhileHeld(t *testing.T) {
+	buf := &syncBuffer{}
+	orig := WarnOutput
+	WarnOutput = buf
+	defer func() { WarnOutput = orig }()
+
+	func() {
+		leaked := &LoggedSyncRWMutex{Name: "TestDetectGCWhileHeld", DetectGCWhileHeld: true}
+		leaked.Lock()
+		released := &LoggedSyncRWMutex{Name: "TestDetectGCReleased", DetectGCWhileHeld: true}
+		released.RLock()
+		released.RUnlock()
+	}()
+
+	// finalizers run at some point after a GC, give them a few rounds
+	for i := 0; i < 20 && !strings.Contains(buf.String(), "GC'd"); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := buf.String()
+	if got == "" {
+		t.Skip("finalizer did not run, GC timing is not deterministic")
+	}
+	if !strings.Contains(got, "[loggedMUTEX] CRITICAL 'TestDetectGCWhileHeld' gc-held: GC'd while held locked=1 rLocked=0") {
+		t.Errorf("should warn about the held mutex, got %q", got)
+	}
+	if strings.Contains(got, "TestDetectGCReleased") {
+		t.Errorf("should not warn about a released mutex, got %q", got)
+	}
+}
+
+func TestDetectGCWhileHeldField(t *testing.T) {
+	buf := &syncBuffer{}
+	orig := WarnOutput
+	WarnOutput = buf
+	defer func() { WarnOutput = orig }()
+
+	type holder struct {
+		n  int
+		mu LoggedSyncRWMutex
+	}
+	func() {
+		// a field at a non-zero offset of an object that has its own finalizer
+		h := &holder{n: 1, mu: LoggedSyncRWMutex{Name: "TestDetectGCField", DetectGCWhileHeld: true}}
+		runtime.SetFinalizer(h, func(*holder) {})
+		h.mu.RLock()
+	}()
+
+	for i := 0; i < 20 && !strings.Contains(buf.String(), "GC'd"); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := buf.String()
+	if got == "" {
+		t.Skip("cleanup did not run, GC timing is not deterministic")
+	}
+	if !strings.Contains(got, "[loggedMUTEX] CRITICAL 'TestDetectGCField' gc-held: GC'd while held locked=0 rLocked=1") {
+		t.Errorf("should warn about the held field, got %q", got)
+	}
+}
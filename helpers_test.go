@@ -0,0 +1,80 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mustPanic fails the test if fn does not panic.
+func mustPanic(t *testing.T, name string, fn func()) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Errorf("%s should panic", name)
+		}
+	}()
+	fn()
+}
+
+// fakeClock replaces the package clock until the test ends.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func useFakeClock(t *testing.T) *fakeClock {
+	c := &fakeClock{t: time.Unix(1000, 0)}
+	orig := now
+	now = c.now
+	t.Cleanup(func() { now = orig })
+	return c
+}
+
+// captureWarnings redirects WarnOutput to a buffer until the test ends.
+func captureWarnings(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	orig := WarnOutput
+	WarnOutput = buf
+	t.Cleanup(func() { WarnOutput = orig })
+	return buf
+}
+
+func lines(buf *bytes.Buffer) []string {
+	s := strings.TrimRight(buf.String(), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// captureOutput redirects Output to a buffer until the test ends.
+func captureOutput(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	orig := Output
+	Output = buf
+	t.Cleanup(func() { Output = orig })
+	return buf
+}
+
+// syncBuffer is a bytes.Buffer safe for use by a background writer.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
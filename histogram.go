@@ -0,0 +1,172 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the hold time histogram,
+// log-scale in 1-2-5 steps from 1µs to 10s. Longer holds go to an overflow bucket.
+var DefaultLatencyBuckets = []time.Duration{
+	time.Microsecond, 2 * time.Microsecond, 5 * time.Microsecond,
+	10 * time.Microsecond, 20 * time.Microsecond, 50 * time.Microsecond,
+	100 * time.Microsecond, 200 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// histogram counts durations in buckets with the given upper bounds.
+type histogram struct {
+	bounds []time.Duration
+	counts []uint64 // len(bounds)+1, the last bucket is the overflow
+	total  uint64
+	max    time.Duration
+}
+
+func newHistogram(bounds []time.Duration) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// add counts d in the first bucket whose bound is >= d.
+func (h *histogram) add(d time.Duration) {
+	i := 0
+	for i < len(h.bounds) && d > h.bounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// percentile returns the upper bound of the bucket containing the q quantile,
+// capped at the longest observed duration.
+func (h *histogram) percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.total))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range h.counts {
+		seen += n
+		if seen >= rank {
+			if i == len(h.bounds) || h.bounds[i] > h.max {
+				return h.max
+			}
+			return h.bounds[i]
+		}
+	}
+	return h.max
+}
+
+// SetLatencyBuckets replaces the hold time histogram of the mutex with one using
+// the given upper bounds, which must be positive and strictly ascending.
+// Recorded holds are discarded. The default is DefaultLatencyBuckets.
+//
+//	err := mux.SetLatencyBuckets([]time.Duration{time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond})
+func (m *LoggedSyncRWMutex) SetLatencyBuckets(bounds []time.Duration) error {
+	for i, b := range bounds {
+		if b <= 0 {
+			return fmt.Errorf("loggedrwmutex: '%s' latency bucket %v is not positive", m.LogName(), b)
+		}
+		if i > 0 && b <= bounds[i-1] {
+			return fmt.Errorf("loggedrwmutex: '%s' latency buckets are not ascending: %v after %v", m.LogName(), b, bounds[i-1])
+		}
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	m.mu.Lock()
+	f := m.feat()
+	f.holdHist = newHistogram(bounds)
+	if f.intervalHist != nil {
+		f.intervalHist = newHistogram(bounds)
+	}
+	m.mu.Unlock()
+	return nil
+}
+
+// holdSample adds a hold duration to the histogram.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) holdSample(d time.Duration) {
+	f := m.feat()
+	if f.holdHist == nil {
+		f.holdHist = newHistogram(DefaultLatencyBuckets)
+	}
+	f.holdHist.add(d)
+	if f.intervalHist != nil {
+		f.intervalHist.add(d)
+	}
+}
+
+// nextInterval returns the hold times since the previous call, nil on the first,
+// and starts a new interval with the latency buckets of the mutex.
+func (m *LoggedSyncRWMutex) nextInterval() *histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bounds := DefaultLatencyBuckets
+	f := m.feat()
+	if f.holdHist != nil {
+		bounds = f.holdHist.bounds
+	}
+	h := f.intervalHist
+	f.intervalHist = newHistogram(bounds)
+	return h
+}
+
+// stopInterval stops collecting interval hold times.
+func (m *LoggedSyncRWMutex) stopInterval() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feat().intervalHist = nil
+}
+
+// HoldPercentile returns the q quantile (0 < q <= 1, e.g. 0.99) of the hold times,
+// at the resolution of the latency buckets. Requires MeasureHold, returns 0 without holds.
+// Read unlocks are matched with the oldest active read lock, so with overlapping
+// readers the distribution is approximate.
+// With HoldSampleEvery only every Nth hold is timed and the percentiles are
+// estimated from these samples: cheaper on the hot path, but rare long holds
+// are likely to be missed, so high percentiles need many samples.
+func (m *LoggedSyncRWMutex) HoldPercentile(q float64) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	if f.holdHist == nil {
+		return 0
+	}
+	return f.holdHist.percentile(q)
+}
+
+// maxReaderConcurrency is the last bucket of ReaderConcurrencyHistogram,
+// it counts all RLocks with at least this many active readers.
+const maxReaderConcurrency = 64
+
+// readerSample counts an RLock in the bucket of the active readers.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) readerSample() {
+	n := min(uint64(m.rLockedCount), maxReaderConcurrency)
+	if uint64(len(m.readerHist)) <= n {
+		m.readerHist = append(m.readerHist, make([]uint64, int(n)+1-len(m.readerHist))...)
+	}
+	m.readerHist[n]++
+}
+
+// ReaderConcurrencyHistogram returns how many RLocks found how many readers active,
+// counting the new reader: {1: 90, 2: 10} means 90 solo reads and 10 reads next to
+// one other reader. Counts of 64 and more are merged into the bucket 64.
+func (m *LoggedSyncRWMutex) ReaderConcurrencyHistogram() map[uint64]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hist := make(map[uint64]uint64)
+	for n, c := range m.readerHist {
+		if c > 0 {
+			hist[uint64(n)] = c
+		}
+	}
+	return hist
+}
@@ -0,0 +1,166 @@
+package loggedrwmutex
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetLatencyBuckets(t *testing.T) {
+	clock := useFakeClock(t)
+	mux := &LoggedSyncRWMutex{Name: "TestSetLatencyBuckets", MeasureHold: true}
+
+	for _, bounds := range [][]time.Duration{
+		{time.Millisecond, 0},
+		{-time.Millisecond},
+		{2 * time.Millisecond, time.Millisecond},
+		{time.Millisecond, time.Millisecond},
+	} {
+		if err := mux.SetLatencyBuckets(bounds); err == nil {
+			t.Errorf("SetLatencyBuckets(%v) should fail", bounds)
+		}
+	}
+
+	bounds := make([]time.Duration, 10)
+	for i := range bounds {
+		bounds[i] = time.Duration(i+1) * time.Millisecond
+	}
+	if err := mux.SetLatencyBuckets(bounds); err != nil {
+		t.Fatalf("SetLatencyBuckets failed: %v", err)
+	}
+	// 90 holds of 3ms, 10 holds of 7ms
+	for i := 0; i < 100; i++ {
+		d := 3 * time.Millisecond
+		if i >= 90 {
+			d = 7 * time.Millisecond
+		}
+		mux.Lock()
+		clock.advance(d)
+		mux.Unlock()
+	}
+	mux.Lock()
+	clock.advance(time.Minute)
+	mux.Unlock()
+
+	h := mux.ext.holdHist
+	if h.counts[2] != 90 || h.counts[6] != 10 || h.counts[10] != 1 {
+		t.Errorf("holds should land in the 3ms, 7ms and overflow buckets, got %v", h.counts)
+	}
+	if p := mux.HoldPercentile(0.5); p != 3*time.Millisecond {
+		t.Errorf("p50 should be 3ms, got %v", p)
+	}
+	if p := mux.HoldPercentile(0.95); p != 7*time.Millisecond {
+		t.Errorf("p95 should be 7ms, got %v", p)
+	}
+	if p := mux.HoldPercentile(1); p != time.Minute {
+		t.Errorf("p100 should be the longest hold, got %v", p)
+	}
+
+	// with the default buckets 3ms lands in the 5ms bucket
+	def := &LoggedSyncRWMutex{Name: "TestDefaultLatencyBuckets", MeasureHold: true}
+	for _, d := range []time.Duration{3 * time.Millisecond, 30 * time.Millisecond} {
+		def.Lock()
+		clock.advance(d)
+		def.Unlock()
+	}
+	if p := def.HoldPercentile(0.5); p != 5*time.Millisecond {
+		t.Errorf("p50 with default buckets should be 5ms, got %v", p)
+	}
+}
+
+func TestHoldSampleEvery(t *testing.T) {
+	clock := useFakeClock(t)
+	full := &LoggedSyncRWMutex{Name: "TestHoldSampleEveryFull", MeasureHold: true, HoldSampleEvery: 1}
+	sampled := &LoggedSyncRWMutex{Name: "TestHoldSampleEvery", MeasureHold: true, HoldSampleEvery: 4}
+	for _, mux := range []*LoggedSyncRWMutex{full, sampled} {
+		for i := 1; i <= 20; i++ {
+			mux.Lock()
+			clock.advance(time.Duration(i) * time.Millisecond)
+			mux.Unlock()
+		}
+	}
+
+	// full sampling times every hold
+	if n := full.ext.holdHist.total; n != 20 {
+		t.Errorf("HoldSampleEvery 1 should time 20 holds, got %d", n)
+	}
+	if p := full.HoldPercentile(0.5); p != 10*time.Millisecond {
+		t.Errorf("p50 should be 10ms, got %v", p)
+	}
+	if avg := full.AvgHold(); avg != 10500*time.Microsecond {
+		t.Errorf("AvgHold should be 10.5ms, got %v", avg)
+	}
+
+	// every 4th hold: 4, 8, 12, 16, 20ms
+	if n := sampled.ext.holdHist.total; n != 5 {
+		t.Errorf("HoldSampleEvery 4 should time 5 holds, got %d", n)
+	}
+	if avg := sampled.AvgHold(); avg != 12*time.Millisecond {
+		t.Errorf("AvgHold of the samples should be 12ms, got %v", avg)
+	}
+
+	// overlapping sampled readers
+	readers := &LoggedSyncRWMutex{Name: "TestHoldSampleEveryReaders", MeasureHold: true, HoldSampleEvery: 2}
+	for i := 0; i < 4; i++ {
+		readers.RLock()
+		clock.advance(time.Millisecond)
+	}
+	for i := 0; i < 4; i++ {
+		readers.RUnlock()
+	}
+	if len(readers.ext.rHoldStarts) != 0 {
+		t.Errorf("all sampled read holds should be finished, %d left", len(readers.ext.rHoldStarts))
+	}
+	if n := readers.ext.holdHist.total; n != 2 {
+		t.Errorf("HoldSampleEvery 2 should time 2 of 4 read holds, got %d", n)
+	}
+}
+
+func TestReaderConcurrencyHistogram(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestReaderConcurrencyHistogram"}
+	if h := mux.ReaderConcurrencyHistogram(); len(h) != 0 {
+		t.Errorf("histogram should be empty before the first RLock, got %v", h)
+	}
+	for i := 0; i < 5; i++ {
+		mux.RLock()
+		mux.RUnlock()
+	}
+
+	// 3 readers at once, each sees 1, 2 or 3 active readers
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mux.RLock()
+			<-release
+			mux.RUnlock()
+		}()
+	}
+	if !mux.WaitReaders(3, time.Second) {
+		t.Fatal("readers should be active")
+	}
+	close(release)
+	wg.Wait()
+
+	mux.Lock() // write locks are not counted
+	mux.Unlock()
+	h := mux.ReaderConcurrencyHistogram()
+	if len(h) != 3 || h[1] != 6 || h[2] != 1 || h[3] != 1 {
+		t.Errorf("histogram should be {1:6 2:1 3:1}, got %v", h)
+	}
+
+	// the last bucket collects everything from maxReaderConcurrency on
+	bunched := &LoggedSyncRWMutex{Name: "TestReaderConcurrencyHistogramBunched"}
+	for i := 0; i < maxReaderConcurrency+6; i++ {
+		bunched.RLock()
+	}
+	for i := 0; i < maxReaderConcurrency+6; i++ {
+		bunched.RUnlock()
+	}
+	h = bunched.ReaderConcurrencyHistogram()
+	if len(h) != maxReaderConcurrency || h[1] != 1 || h[maxReaderConcurrency-1] != 1 || h[maxReaderConcurrency] != 7 {
+		t.Errorf("readers beyond %d should share the last bucket, got %v", maxReaderConcurrency, h)
+	}
+}
@@ -0,0 +1,41 @@
+package loggedrwmutex
+
+// remember adds e to the history ring buffer.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) remember(e Event) {
+	f := m.feat()
+	if cap(f.history) != m.HistorySize {
+		// HistorySize changed, start over
+		f.history = make([]Event, 0, m.HistorySize)
+		f.historyNext = 0
+	}
+	if len(f.history) < cap(f.history) {
+		f.history = append(f.history, e)
+		return
+	}
+	f.history[f.historyNext] = e
+	f.historyNext = (f.historyNext + 1) % len(f.history)
+}
+
+// History returns the retained events, oldest first. Requires HistorySize.
+func (m *LoggedSyncRWMutex) History() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	events := make([]Event, 0, len(f.history))
+	events = append(events, f.history[f.historyNext:]...)
+	return append(events, f.history[:f.historyNext]...)
+}
+
+// EventBySeq returns the retained event with the sequence number seq
+// and whether it is still retained. Requires HistorySize.
+func (m *LoggedSyncRWMutex) EventBySeq(seq uint64) (Event, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.view().history {
+		if e.Seq == seq {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
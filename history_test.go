@@ -0,0 +1,115 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventBySeq(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestEventBySeq", HistorySize: 3}
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.RUnlock()
+
+	// seq 1-3 have been evicted
+	if _, ok := mux.EventBySeq(2); ok {
+		t.Error("EventBySeq(2) should be evicted")
+	}
+	e, ok := mux.EventBySeq(4)
+	if !ok {
+		t.Fatal("EventBySeq(4) should be retained")
+	}
+	if e.Seq != 4 || e.Op != "RLock" || e.Name != "TestEventBySeq" || e.RLocked != 2 || e.Locked != 0 {
+		t.Errorf("EventBySeq(4) returned unexpected event %+v", e)
+	}
+	if e.Goroutine != goid() {
+		t.Errorf("Goroutine should be %d, got %d", goid(), e.Goroutine)
+	}
+	if !strings.HasPrefix(e.Caller, "history_test.go:") {
+		t.Errorf("Caller should point into the test, got %q", e.Caller)
+	}
+	if e.Time.IsZero() {
+		t.Error("Time should be set")
+	}
+
+	var seqs []uint64
+	for _, e := range mux.History() {
+		seqs = append(seqs, e.Seq)
+		if !strings.HasPrefix(e.Caller, "history_test.go:") {
+			t.Errorf("Caller of %s should point into the test, got %q", e.Op, e.Caller)
+		}
+	}
+	if len(seqs) != 3 || seqs[0] != 4 || seqs[2] != 6 {
+		t.Errorf("History should return seq 4-6, got %v", seqs)
+	}
+}
+
+func TestHistoryMinHold(t *testing.T) {
+	clock := useFakeClock(t)
+	mux := &LoggedSyncRWMutex{Name: "TestHistoryMinHold", MeasureHold: true, HistorySize: 10, HistoryMinHold: 10 * time.Millisecond}
+	hold := func(lock, unlock func(), d time.Duration) {
+		lock()
+		clock.advance(d)
+		unlock()
+	}
+	hold(mux.Lock, mux.Unlock, time.Millisecond)
+	hold(mux.Lock, mux.Unlock, 20*time.Millisecond)
+	hold(mux.RLock, mux.RUnlock, 2*time.Millisecond)
+	hold(mux.RLock, mux.RUnlock, 15*time.Millisecond)
+
+	var got []string
+	for _, e := range mux.History() {
+		got = append(got, e.Op+" "+e.Duration.String())
+		if e.Seq != 4 && e.Seq != 8 {
+			t.Errorf("only the releases of the long holds should be retained, got seq %d", e.Seq)
+		}
+	}
+	if strings.Join(got, ",") != "Unlock 20ms,RUnlock 15ms" {
+		t.Errorf("History should only contain the long holds, got %q", got)
+	}
+}
+
+func TestHistoryMinWait(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestHistoryMinWait", MeasureContention: true, HistorySize: 10, HistoryMinWait: 10 * time.Millisecond}
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	events := mux.History()
+	if len(events) != 1 || events[0].Op != "RLock" || events[0].Duration < 10*time.Millisecond {
+		t.Errorf("History should only contain the waiting RLock, got %+v", events)
+	}
+}
+
+func TestEventCallerWrappers(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestEventCallerWrappers", HistorySize: 8}
+	if !mux.LockTimeout(time.Second) {
+		t.Fatal("LockTimeout should acquire a free mutex")
+	}
+	mux.Unlock()
+	if !mux.RLockPolite() {
+		t.Fatal("RLockPolite should acquire without a queued writer")
+	}
+	mux.RUnlock()
+
+	events := mux.History()
+	if len(events) != 4 {
+		t.Fatalf("should record 4 events, got %d", len(events))
+	}
+	for _, e := range events {
+		if !strings.HasPrefix(e.Caller, "history_test.go:") {
+			t.Errorf("Caller of %s should point into the test, got %q", e.Op, e.Caller)
+		}
+	}
+}
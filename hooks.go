@@ -0,0 +1,27 @@
+package loggedrwmutex
+
+import (
+	"time"
+)
+
+// OnFirstUse is called exactly once per mutex after its first Lock or RLock,
+// e.g. to register it lazily. It runs while the caller holds the lock
+// and must not lock the mutex again.
+var OnFirstUse func(m *LoggedSyncRWMutex)
+
+// OnSlowHold is called by StartSlowHoldAlert for every registered mutex
+// whose p99 hold time over an interval exceeds the threshold.
+var OnSlowHold func(name string, p99 time.Duration)
+
+// heartbeat returns OnHeartbeat and the stats to call it with
+// for every HeartbeatEvery acquisitions, the callback is run outside of m.mu.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) heartbeat() (func(Stats), Stats) {
+	if m.HeartbeatEvery == 0 || m.OnHeartbeat == nil {
+		return nil, Stats{}
+	}
+	if (uint64(m.totalLocked)+uint64(m.totalrLocked))%m.HeartbeatEvery != 0 {
+		return nil, Stats{}
+	}
+	return m.OnHeartbeat, m.stats()
+}
@@ -0,0 +1,69 @@
+package loggedrwmutex
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHeartbeat(t *testing.T) {
+	var beats []Stats
+	mux := &LoggedSyncRWMutex{Name: "TestHeartbeat", HeartbeatEvery: 100}
+	mux.OnHeartbeat = func(st Stats) { beats = append(beats, st) }
+
+	for i := 0; i < 60; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	for i := 0; i < 40; i++ {
+		mux.RLock()
+		mux.RUnlock()
+	}
+	if len(beats) != 1 {
+		t.Fatalf("OnHeartbeat should fire once after 100 acquisitions, got %d", len(beats))
+	}
+	if got := beats[0]; got.TotalLocked != 60 || got.TotalRLocked != 40 || got.RLocked != 1 {
+		t.Errorf("heartbeat stats should be taken at the 100th acquisition, got %+v", got)
+	}
+
+	for i := 0; i < 99; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	if len(beats) != 1 {
+		t.Errorf("OnHeartbeat should not fire before 200 acquisitions, got %d", len(beats))
+	}
+}
+
+func TestOnFirstUse(t *testing.T) {
+	calls := map[*LoggedSyncRWMutex]int{}
+	var hookMu sync.Mutex
+	OnFirstUse = func(m *LoggedSyncRWMutex) {
+		hookMu.Lock()
+		calls[m]++
+		hookMu.Unlock()
+	}
+	defer func() { OnFirstUse = nil }()
+
+	a := &LoggedSyncRWMutex{Name: "TestOnFirstUseA"}
+	b := &LoggedSyncRWMutex{Name: "TestOnFirstUseB"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				a.Lock()
+				a.Unlock()
+				a.RLock()
+				a.RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+	b.RLock()
+	b.RUnlock()
+
+	if len(calls) != 2 || calls[a] != 1 || calls[b] != 1 {
+		t.Errorf("OnFirstUse should fire once per mutex, got a=%d b=%d of %d", calls[a], calls[b], len(calls))
+	}
+}
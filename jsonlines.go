@@ -0,0 +1,79 @@
+package loggedrwmutex
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// JSONLines makes all mutexes log every operation as one JSON object per line (NDJSON)
+// instead of the text format, a mutex can enable it for itself with its JSONLines field:
+//
+//	{"op":"Lock","name":"ResourceMutex","locked":1,"rlocked":0,"seq":7,"time":"2025-06-01T12:00:00.123456789Z"}
+var JSONLines = false
+
+// jsonEvent is the object written per operation with JSONLines.
+type jsonEvent struct {
+	Op      string    `json:"op"`
+	Name    string    `json:"name"`
+	Locked  uint64    `json:"locked"`
+	RLocked uint64    `json:"rlocked"`
+	Seq     uint64    `json:"seq"`
+	Time    time.Time `json:"time"`
+	Ver     string    `json:"ver,omitempty"` // VersionTag
+}
+
+// logJSON writes the JSON line of one operation.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) logJSON(op byte) {
+	if m.withoutLogging {
+		return
+	}
+	m.writeDone(m.writeJSON(m.output(), op, m.logName(), m.rLockedCount))
+}
+
+// writeJSON writes the JSON line of one operation of the mutex name to w
+// unless logging is paused or has been stopped, and returns the error of the write.
+// Must be called with c.mu held.
+func (c *lockCore) writeJSON(w io.Writer, op byte, name string, rLocked counter) error {
+	if loggingPaused.Load() || c.writeDisabled {
+		return nil
+	}
+	b, err := json.Marshal(jsonEvent{
+		Op:      opNames[op],
+		Name:    name,
+		Locked:  uint64(c.lockedCount),
+		RLocked: uint64(rLocked),
+		Seq:     c.seq,
+		Time:    now(),
+		Ver:     VersionTag,
+	})
+	if err != nil {
+		return nil
+	}
+	return safeWrite(w, append(b, '\n'))
+}
+
+// jsonWarning is the object written per warning with JSONLines.
+type jsonWarning struct {
+	Severity string    `json:"severity"`
+	Warning  string    `json:"warning"`
+	Name     string    `json:"name"`
+	Msg      string    `json:"msg"`
+	Time     time.Time `json:"time"`
+}
+
+// warnJSON writes the JSON line of one warning to WarnOutput.
+func warnJSON(name string, sev Severity, kind string, msg string, t time.Time) {
+	b, err := json.Marshal(jsonWarning{
+		Severity: sev.String(),
+		Warning:  kind,
+		Name:     name,
+		Msg:      msg,
+		Time:     t,
+	})
+	if err != nil {
+		return
+	}
+	safeWrite(WarnOutput, append(b, '\n'))
+}
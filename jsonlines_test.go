@@ -0,0 +1,51 @@
+package loggedrwmutex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestJSONLines(t *testing.T) {
+	clock := useFakeClock(t)
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestJSONLines", DebugAll: true, JSONLines: true}
+
+	mux.Lock()
+	clock.advance(time.Millisecond)
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+
+	want := []jsonEvent{
+		{Op: "Lock", Name: "TestJSONLines", Locked: 1, Seq: 1, Time: time.Unix(1000, 0)},
+		{Op: "Unlock", Name: "TestJSONLines", Seq: 2, Time: time.Unix(1000, 0).Add(time.Millisecond)},
+		{Op: "RLock", Name: "TestJSONLines", RLocked: 1, Seq: 3, Time: time.Unix(1000, 0).Add(time.Millisecond)},
+		{Op: "RUnlock", Name: "TestJSONLines", Seq: 4, Time: time.Unix(1000, 0).Add(time.Millisecond)},
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log %d JSON lines, got %q", len(want), got)
+	}
+	for i, line := range got {
+		var e jsonEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("line %d is no JSON object: %v: %q", i, err, line)
+		}
+		if e.Op != want[i].Op || e.Name != want[i].Name || e.Locked != want[i].Locked || e.RLocked != want[i].RLocked || e.Seq != want[i].Seq || !e.Time.Equal(want[i].Time) {
+			t.Errorf("line %d should be %+v, got %+v", i, want[i], e)
+		}
+	}
+
+	// the package var enables it for all mutexes
+	buf.Reset()
+	JSONLines = true
+	defer func() { JSONLines = false }()
+	other := &LoggedSyncRWMutex{Name: "TestJSONLinesGlobal", DebugLock: DebugOn}
+	other.Lock()
+	other.Unlock()
+	var e jsonEvent
+	if got := lines(buf); len(got) != 1 || json.Unmarshal([]byte(got[0]), &e) != nil || e.Op != "Lock" {
+		t.Errorf("global JSONLines should log a JSON line, got %q", got)
+	}
+}
@@ -0,0 +1,44 @@
+package loggedrwmutex
+
+// MaxTrackedEntries caps the per-mutex maps of the debugging features that grow
+// with the number of goroutines or call sites: the read lock holders of ownership
+// tracking and the caller stacks of SampleCallersEvery. When a map is full the
+// oldest read holder or the least sampled stack is evicted and counted in
+// Stats.TrackingEvictions. 0 disables the cap. UniqueGoroutines is bounded
+// separately by MaxUniqueGoroutines.
+var MaxTrackedEntries = 10000
+
+// full reports whether a tracking map with n entries is at MaxTrackedEntries.
+func full(n int) bool {
+	return MaxTrackedEntries > 0 && n >= MaxTrackedEntries
+}
+
+// evictReadOwner forgets the goroutine holding a read lock the longest.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) evictReadOwner() {
+	var oldest int64
+	f := m.feat()
+	for gid, since := range f.readSince {
+		if oldest == 0 || since.Before(f.readSince[oldest]) {
+			oldest = gid
+		}
+	}
+	delete(f.readOwners, oldest)
+	delete(f.readSince, oldest)
+	f.trackingEvictions++
+}
+
+// evictCallerSample forgets the least sampled caller stack.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) evictCallerSample() {
+	var least string
+	first := true
+	f := m.feat()
+	for stack, n := range f.callerSamples {
+		if first || n < f.callerSamples[least] {
+			least, first = stack, false
+		}
+	}
+	delete(f.callerSamples, least)
+	f.trackingEvictions++
+}
@@ -0,0 +1,57 @@
+package loggedrwmutex
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMaxTrackedEntries(t *testing.T) {
+	defer func(n int) { MaxTrackedEntries = n }(MaxTrackedEntries)
+	MaxTrackedEntries = 3
+	mux := &LoggedSyncRWMutex{Name: "TestMaxTrackedEntries", TrackOwnership: true}
+
+	// 5 goroutines keep a read lock each
+	var hold, release, done sync.WaitGroup
+	release.Add(1)
+	for i := 0; i < 5; i++ {
+		hold.Add(1)
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			mux.RLock()
+			hold.Done()
+			release.Wait()
+			mux.RUnlock()
+		}()
+		hold.Wait() // in order, so the first ones are the oldest
+	}
+	mux.mu.Lock()
+	owners := len(mux.ext.readOwners)
+	mux.mu.Unlock()
+	if owners != 3 {
+		t.Errorf("read owners should be capped at 3, got %d", owners)
+	}
+	if n := mux.Snapshot().TrackingEvictions; n != 2 {
+		t.Errorf("TrackingEvictions should be 2, got %d", n)
+	}
+	release.Done()
+	done.Wait()
+
+	// caller stacks
+	sampled := &LoggedSyncRWMutex{Name: "TestMaxTrackedEntriesCallers", SampleCallersEvery: 1}
+	sampled.feat().callerSamples = map[string]uint64{"a": 5, "b": 1, "c": 3}
+	sampled.mu.Lock()
+	for i := 0; i < 4; i++ {
+		sampled.sampleCaller(0) // the same new stack
+	}
+	sampled.mu.Unlock()
+	if len(sampled.ext.callerSamples) != 3 {
+		t.Errorf("caller samples should be capped at 3, got %d: %v", len(sampled.ext.callerSamples), sampled.ext.callerSamples)
+	}
+	if _, ok := sampled.ext.callerSamples["b"]; ok {
+		t.Errorf("the least sampled stack should be evicted, got %v", sampled.ext.callerSamples)
+	}
+	if n := sampled.ext.trackingEvictions; n != 1 {
+		t.Errorf("TrackingEvictions should be 1, got %d", n)
+	}
+}
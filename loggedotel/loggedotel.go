@@ -0,0 +1,45 @@
+// Package loggedotel records lock holds of a loggedrwmutex.LoggedSyncRWMutex as OpenTelemetry spans.
+// It is a separate module to keep the otel dependency out of the core module.
+package loggedotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/go-while/go-loggedrwmutex"
+)
+
+// TracerName is the name of the tracer spans are started with.
+const TracerName = "github.com/go-while/go-loggedrwmutex"
+
+// LockSpan starts a span named after the mutex as child of the span in ctx,
+// locks m and returns the span context and a release func that unlocks m and ends the span.
+// The span covers the wait for the lock and the hold.
+//
+//	ctx, release := loggedotel.LockSpan(ctx, mux)
+//	defer release()
+func LockSpan(ctx context.Context, m *loggedrwmutex.LoggedSyncRWMutex) (context.Context, func()) {
+	ctx, span := start(ctx, m, "write")
+	m.Lock()
+	return ctx, func() {
+		m.Unlock()
+		span.End()
+	}
+}
+
+// RLockSpan is like LockSpan for a read lock.
+func RLockSpan(ctx context.Context, m *loggedrwmutex.LoggedSyncRWMutex) (context.Context, func()) {
+	ctx, span := start(ctx, m, "read")
+	m.RLock()
+	return ctx, func() {
+		m.RUnlock()
+		span.End()
+	}
+}
+
+func start(ctx context.Context, m *loggedrwmutex.LoggedSyncRWMutex, mode string) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, m.LogName(), trace.WithAttributes(attribute.String("lock.mode", mode)))
+}
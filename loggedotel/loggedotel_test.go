@@ -0,0 +1,48 @@
+package loggedotel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/go-while/go-loggedrwmutex"
+)
+
+func TestLockSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	orig := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(orig)
+
+	mux := &loggedrwmutex.LoggedSyncRWMutex{Name: "TestLockSpan"}
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+
+	_, release := LockSpan(ctx, mux)
+	if st := mux.Snapshot(); st.Locked != 1 {
+		t.Errorf("LockSpan should hold the lock, got locked=%d", st.Locked)
+	}
+	release()
+	_, release = RLockSpan(ctx, mux)
+	release()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("should record 3 spans, got %d", len(spans))
+	}
+	for _, span := range spans[:2] {
+		if span.Name() != "TestLockSpan" {
+			t.Errorf("span should be named after the mutex, got %q", span.Name())
+		}
+		if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %q should be a child of the parent span", span.Name())
+		}
+	}
+	if st := mux.Snapshot(); st.Locked != 0 || st.RLocked != 0 {
+		t.Errorf("release should unlock, got %+v", st)
+	}
+}
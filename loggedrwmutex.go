@@ -2,13 +2,24 @@ package loggedrwmutex
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sync"
+	"time"
 )
 
 // Any Debug flags can only be set on boot time / before initializing any mutexes
 // or you may get data race errors. this is a non fix as this package is only for debugging purposes.
 var GlobalDebug = false    // global debug flag for all mutexes
-var DisableLogging = false // global flag to disable logging and bypass directly to original mutexes without counting
+var DisableLogging = false // global flag to disable logging and bypass directly to original mutexes without counting, except for mutexes with ForceLogging set
+
+// NamePrefix is prepended to the Name of every mutex in log output,
+// e.g. to group the mutexes of one library. The stored Name is not changed.
+var NamePrefix string
+
+// Output receives all log lines, defaults to stdout.
+// A mutex with Writer set logs to its Writer instead.
+var Output io.Writer = os.Stdout
 
 // LoggedSyncRWMutex is a mutex that logs its actions.
 // It wraps sync.Mutex and sync.RWMutex to provide logging for lock and unlock actions.
@@ -24,97 +35,546 @@ var DisableLogging = false // global flag to disable logging and bypass directly
 //		mux := &loggedrwmutexLoggedSyncRWMutex{Name: "XXYYZZ" }'
 //		item.mux = mux
 //		item.mux.DebugAll = true // enables all debug messages
-//		item.mux.DebugLock = true // enables debug messages for Lock
-//		item.mux.DebugUnlock = true // enables debug messages for Unlock
-//		item.mux.DebugRLock = true // enables debug messages for RLock
-//		item.mux.DebugRUnlock = true // enables debug messages for RUnlock
+//		item.mux.DebugLock = loggedrwmutex.DebugOn // enables debug messages for Lock
+//		item.mux.DebugUnlock = loggedrwmutex.DebugOn // enables debug messages for Unlock
+//		item.mux.DebugRLock = loggedrwmutex.DebugOn // enables debug messages for RLock
+//		item.mux.DebugRUnlock = loggedrwmutex.DebugOn // enables debug messages for RUnlock
+//		item.mux.DebugRUnlock = loggedrwmutex.DebugOff // disables debug messages for RUnlock, even with DebugAll
 //		item.mux.Lock()           // locks the mutex
 //		item.mux.Unlock()         // unlocks the mutex
 //		item.mux.RLock()          // acquires a read lock
 //		item.mux.RUnlock()        // releases a read lock
 //		locked, rlocked := item.mux.Status(true) // checks the status of the mutex
 type LoggedSyncRWMutex struct {
-	mu             sync.RWMutex // internal mutex to protect the state of the LoggedSyncRWMutex
-	Name           string
-	DebugAll       bool   // if true, will print debug messages
-	DebugLock      bool   // if true, will print debug messages
-	DebugUnlock    bool   // if true, will print debug messages
-	DebugRLock     bool   // if true, will print debug messages
-	DebugRUnlock   bool   // if true, will print debug messages
-	lockedCount    uint64 // number of active locks
-	rLockedCount   uint64 // number of active readers
-	totalLocked    uint64
-	totalUnlocked  uint64
-	totalrLocked   uint64
-	totalrUnlocked uint64
-	sync.RWMutex   // the actual mutex that will be used for locking
+	lockCore             // internal mutex, write lock counters and log output state, shared with LoggedSyncMutex
+	Name                 string
+	Group                string    // optional subsystem name to aggregate mutexes in the registry, see GroupWaitStats
+	ForceLogging         bool      // if true, this mutex keeps counting and logging even when DisableLogging is set
+	Writer               io.Writer // if set, receives the log lines of this mutex instead of Output
+	label                string    // label of the write lock held via LockLabeled
+	withoutLogging       bool      // logging suspended by WithoutLogging
+	initWarned           bool      // WarnInitLocks warning has been written
+	firstUseOnce         sync.Once // runs OnFirstUse
+	DebugAll             bool      // if true, will print debug messages
+	DebugLock            DebugFlag // DebugOn/DebugOff force debug messages for Lock on/off, DebugDefault follows DebugAll
+	DebugUnlock          DebugFlag // DebugOn/DebugOff force debug messages for Unlock on/off, DebugDefault follows DebugAll
+	DebugRLock           DebugFlag // DebugOn/DebugOff force debug messages for RLock on/off, DebugDefault follows DebugAll
+	DebugRUnlock         DebugFlag // DebugOn/DebugOff force debug messages for RUnlock on/off, DebugDefault follows DebugAll
+	debugWindows         int       // DebugFor windows that have not expired
+	debugPrev            bool      // DebugAll before the first of the debugWindows
+	readerWaiters        counter   // goroutines in RLock that have not acquired yet
+	rLockedCount         counter   // number of active readers
+	totalrLocked         counter
+	totalrUnlocked       counter
+	resetLocked          counter // active locks at the last SnapshotAndReset, released after it
+	resetRLocked         counter
+	totalWeight          counter           // sum of the weights of all write locks, see LockWeighted
+	TrackOwnership       bool              // if true, records which goroutines hold the lock (see AssertHeld)
+	DetectRLockThenLock  bool              // if true, reports a goroutine calling Lock while holding a read lock (self-deadlock)
+	DetectLockThenRLock  bool              // if true, reports a goroutine calling RLock while holding the write lock (self-deadlock)
+	StrictOwnership      bool              // if true, Unlock and RUnlock panic when called by a goroutine not holding the lock
+	RecordUse            bool              // if true, records the first and last operation, see Stats.FirstUse
+	CountGoroutines      bool              // if true, counts the distinct goroutines acquiring the lock, see UniqueGoroutines
+	ext                  *features         // state of the debugging features, nil until one is used, see feat
+	HistorySize          int               // if > 0, the last HistorySize events are retained, see History and EventBySeq
+	HistoryMinHold       time.Duration     // if > 0 or HistoryMinWait > 0, only Unlock and RUnlock events of holds of at least HistoryMinHold are retained, requires MeasureHold
+	HistoryMinWait       time.Duration     // if > 0 or HistoryMinHold > 0, only Lock and RLock events of waits of at least HistoryMinWait are retained, requires MeasureContention
+	DeadlockTimeout      time.Duration     // if > 0, Lock and RLock waiting longer than this report a possible deadlock as set by OnDeadlock
+	OnDeadlock           DeadlockAction    // what happens after DeadlockTimeout, defaults to DeadlockWarn
+	MeasureContention    bool              // if true, measures how long Lock and RLock wait for the lock
+	ContentionMode       ContentionMode    // how MeasureContention measures, defaults to ContentionTimed
+	MeasureHold          bool              // if true, measures how long locks are held
+	HoldSampleEvery      uint64            // with MeasureHold, if > 1 only every HoldSampleEvery hold is timed, see HoldPercentile
+	readerHist           []uint64          // RLocks by the number of active readers including itself, see ReaderConcurrencyHistogram
+	CompareBaseline      bool              // if true, every operation is repeated on a plain baseline mutex to estimate the overhead, see OverheadEstimate
+	TrivialHoldThreshold time.Duration     // with MeasureHold, holds shorter than this are counted as trivial
+	TrivialHoldWarnRatio float64           // if > 0, warns when the ratio of trivial holds exceeds it
+	DetectInversion      bool              // with MeasureHold, warns about a possible priority inversion when a hold of at least InversionHold ends while InversionWaiters goroutines wait
+	InversionHold        time.Duration     // long hold for DetectInversion, defaults to 100ms
+	InversionWaiters     int               // waiters for DetectInversion, defaults to 2
+	HeartbeatEvery       uint64            // if > 0, calls OnHeartbeat every HeartbeatEvery acquisitions (Lock and RLock)
+	OnHeartbeat          func(stats Stats) // called with a snapshot while the caller holds the lock, must not lock the mutex again
+	DebugInvariants      bool              // if true, checks CheckInvariants after every operation and reports violations as misuse
+	StrictInvariants     bool              // if true, Lock reports a write lock that is not exclusive in the counters as misuse
+	ProfileLabels        bool              // if true, goroutines waiting in Lock and RLock carry the pprof label blocked_on=<name>; Lock and RLock reset the goroutine's labels afterwards, LockCtx/RLockCtx restore those of ctx
+	RaceAnnotations      bool              // if true and built with -race, Lock and Unlock are annotated for the race detector via runtime.RaceAcquire and RaceRelease, a no-op otherwise
+	DetectGCWhileHeld    bool              // if true, warns when the mutex is garbage collected while held. Uses runtime.AddCleanup, the warning only appears if a GC runs before exit
+	LogStackDepth        bool              // if true, log lines include the stack depth of the calling goroutine as depth=
+	LogGaps              bool              // if true, log lines include the time since the previous logged op of the same type as gap=
+	SampleCallersEvery   uint64            // if > 0, records the caller stack of every SampleCallersEvery acquisitions, see WriteFolded
+	AutoDisableRate      int               // if > 0, logging of this mutex is disabled once it logs more than AutoDisableRate lines per second
+	AutoDisableCooldown  time.Duration     // if > 0, logging disabled by AutoDisableRate is re-enabled after this duration
+	JSONLines            bool              // if true, logs every operation as a JSON line, see the package var JSONLines
+	CompactOps           bool              // if true, prefixes log lines with a one letter op code: [L] Lock, [U] Unlock, [r] RLock, [u] RUnlock
+	CompactOpsOnly       bool              // with CompactOps, omits the op word after the op code
+	sync.RWMutex                           // the actual mutex that will be used for locking
+	DedupeConsecutive    bool              // if true, collapses log lines repeating one of the two lines before apart from their totals, e.g. Lock/Unlock cycles, see FlushDedupe
+	PairedLogging        bool              // if true, logs one line per critical section on Unlock and RUnlock with held= (MeasureHold) and waited= (MeasureContention)
+	// OnHold is called with MeasureHold after Unlock and RUnlock with the duration of every timed hold,
+	// outside of the internal lock so it may use the mutex.
+	OnHold func(op string, d time.Duration)
 }
 
 // Status prints the current status of the mutex, including whether it is locked or read-locked.
+// For compatibility with existing log parsers the line keeps its original format,
+// it does not follow FieldSeparator. DumpAll writes the same counters as key=value fields.
 func (m *LoggedSyncRWMutex) PrintStatus(forceprint bool) (locked bool, rlocked bool) {
-	if !DisableLogging {
+	if m.disabled() {
 		return
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.lockedCount > 0 || m.rLockedCount > 0 || forceprint {
-		fmt.Printf("?? [loggedMUTEX] Status '%s' locked=%d, rLocked=%d totalLocked/totalUnlocked=%d/%d totalrLocked/totalrUnlocked=%d/%d\n", m.Name, m.lockedCount, m.rLockedCount, m.totalLocked, m.totalUnlocked, m.totalrLocked, m.totalrUnlocked)
+		fmt.Fprintf(m.output(), "?? [loggedMUTEX] Status '%s' locked=%d, rLocked=%d totalLocked/totalUnlocked=%d/%d totalrLocked/totalrUnlocked=%d/%d\n", m.logName(), m.lockedCount, m.rLockedCount, m.totalLocked, m.totalUnlocked, m.totalrLocked, m.totalrUnlocked)
 	}
 	return
 }
 
+// disabled reports whether counting and logging are bypassed for this mutex:
+// DisableLogging applies to all mutexes except those with ForceLogging set.
+func (m *LoggedSyncRWMutex) disabled() bool {
+	return DisableLogging && !m.ForceLogging
+}
+
 func (m *LoggedSyncRWMutex) Lock() {
-	if !DisableLogging {
-		m.mu.Lock()
-		m.lockedCount++
-		m.totalLocked++
-		if m.DebugLock || m.DebugAll || GlobalDebug {
-			fmt.Printf("[loggedMUTEX] Lock '%s' locked=%d/%d\n", m.Name, m.lockedCount, m.totalLocked)
-		}
-		m.mu.Unlock()
+	st := m.prepare(true)
+
+	m.acquire(&st, true)
+
+	m.acquired(st, true)
+}
+
+// acquire blocks in the embedded Lock or RLock, through acquireSlow
+// if ContentionTryFirst, DeadlockTimeout or ProfileLabels watch the wait.
+func (m *LoggedSyncRWMutex) acquire(st *acquireState, write bool) {
+	if st.deadlockTimeout > 0 || st.tryFirst || st.profile {
+		m.acquireSlow(st, write)
+		return
 	}
+	if write {
+		m.RWMutex.Lock()
+	} else {
+		m.RWMutex.RLock()
+	}
+}
 
-	m.RWMutex.Lock()
+// LockLabeled locks the mutex like Lock and adds label to the log line,
+// the matching Unlock repeats the label:
+//
+//	[loggedMUTEX] Lock 'ResourceMutex' (flush) locked=1/3
+func (m *LoggedSyncRWMutex) LockLabeled(label string) {
+	st := m.prepare(true)
+	st.label = label
+
+	m.acquire(&st, true)
+
+	m.acquired(st, true)
+}
+
+// LockWeighted locks the mutex like Lock and adds w instead of 1 to the total weight
+// of all write locks, see Stats.TotalWeight. The weight is only reported, e.g. for
+// capacity planning with a semaphore layered on top, it does not change locking.
+func (m *LoggedSyncRWMutex) LockWeighted(w int) {
+	st := m.prepare(true)
+	st.weight = w
+
+	m.acquire(&st, true)
+
+	m.acquired(st, true)
 }
 
 func (m *LoggedSyncRWMutex) Unlock() {
+	rs := m.releasing(true)
+	var start time.Time
+	if rs.timed {
+		start = now()
+	}
+
 	m.RWMutex.Unlock()
 
-	if !DisableLogging {
-		m.mu.Lock()
-		m.lockedCount--
-		m.totalUnlocked++
-		if m.DebugUnlock || m.DebugAll || GlobalDebug {
-			fmt.Printf("[loggedMUTEX] Unlock '%s' locked=%d/%d\n", m.Name, m.lockedCount, m.totalUnlocked)
+	m.released(rs, start)
+}
+
+func (m *LoggedSyncRWMutex) RLock() {
+	st := m.prepare(false)
+
+	m.acquire(&st, false)
+
+	m.acquired(st, false)
+}
+
+func (m *LoggedSyncRWMutex) RUnlock() {
+	rs := m.releasing(false)
+	var start time.Time
+	if rs.timed {
+		start = now()
+	}
+
+	m.RWMutex.RUnlock()
+
+	m.released(rs, start)
+}
+
+// RLockPolite acquires a read lock like RLock unless a goroutine is waiting in Lock,
+// then it returns false without acquiring, so readers do not starve a queued writer.
+// The check is approximate: a writer queueing right after it is not seen,
+// and without logging enabled no waiters are counted.
+func (m *LoggedSyncRWMutex) RLockPolite() bool {
+	m.mu.Lock()
+	queued := m.writerWaiters > 0
+	m.mu.Unlock()
+	if queued {
+		return false
+	}
+	m.RLock()
+	return true
+}
+
+// acquireState carries what has to be recorded once the embedded lock is held.
+type acquireState struct {
+	enabled   bool          // counting is enabled
+	id        any           // optional correlation id for the log line
+	label     string        // optional label of LockLabeled
+	weight    int           // weight of LockWeighted, 1 for other write locks
+	gid       int64         // calling goroutine if needed
+	track     bool          // record the owning goroutine
+	ordered   bool          // record the held name for EnforceOrder
+	waitStart time.Time     // start of the wait if MeasureContention is set
+	hold      bool          // track hold timing
+	sampled   bool          // time this hold, see HoldSampleEvery
+	baseMu    *sync.RWMutex // baseline mutex to perform the operation on too, nil without CompareBaseline
+	opStart   time.Time     // start of the operation if baseMu is set
+
+	deadlockTimeout time.Duration  // DeadlockTimeout
+	deadlockAction  DeadlockAction // OnDeadlock
+	tryFirst        bool           // ContentionTryFirst
+	contended       bool           // the lock was not free with tryFirst
+	uncontended     bool           // the first try of tryFirst acquired the lock
+	race            bool           // RaceAnnotations
+	profile         bool           // ProfileLabels
+	labelName       string         // logName for the ProfileLabels label, read under m.mu
+}
+
+// prepare returns the acquireState for the current configuration
+// and runs the misuse checks before the caller blocks.
+func (m *LoggedSyncRWMutex) prepare(write bool) acquireState {
+	return m.prepareAcquire(write, true)
+}
+
+// prepareTry is prepare for a TryLock, which does not block and writes no waiting line.
+func (m *LoggedSyncRWMutex) prepareTry(write bool) acquireState {
+	return m.prepareAcquire(write, false)
+}
+
+func (m *LoggedSyncRWMutex) prepareAcquire(write, blocking bool) (st acquireState) {
+	if m.disabled() {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st.enabled = true
+	st.weight = 1
+	st.track = m.tracksOwners()
+	st.ordered = orderActive.Load()
+	if st.track || st.ordered || m.CountGoroutines || IndentByDepth {
+		st.gid = goid()
+	}
+	if st.ordered {
+		m.checkOrder(st.gid, lockOp(write))
+	}
+	m.checkInit(write)
+	if m.DetectGCWhileHeld {
+		m.watchGC()
+	}
+	if write && m.DetectRLockThenLock && m.view().readOwners[st.gid] > 0 {
+		m.misuse(MisuseSelfDeadlock, opLock, "goroutine %d calls Lock while holding a read lock, this never returns", st.gid)
+	}
+	if !write && m.DetectLockThenRLock && m.view().writeOwner == st.gid {
+		m.misuse(MisuseSelfDeadlock, opRLock, "goroutine %d calls RLock while holding the write lock, this never returns", st.gid)
+	}
+	st.hold = m.MeasureHold
+	st.sampled = st.hold && m.holdSampled()
+	if m.CompareBaseline {
+		st.baseMu = &m.feat().baseline
+		st.opStart = now()
+	}
+	if m.MeasureContention {
+		if m.ContentionMode == ContentionTryFirst {
+			st.tryFirst = true
+		} else {
+			st.waitStart = now()
 		}
-		m.mu.Unlock()
 	}
+	st.deadlockTimeout, st.deadlockAction = m.DeadlockTimeout, m.OnDeadlock
+	st.race = m.RaceAnnotations
+	st.profile = m.ProfileLabels
+	if st.profile {
+		st.labelName = m.logName()
+	}
+	if blocking {
+		m.logWaiting(write)
+	}
+	if write {
+		m.writerWaiters++
+	} else {
+		m.readerWaiters++
+	}
+	return
 }
 
-func (m *LoggedSyncRWMutex) RLock() {
-	if !DisableLogging {
+// logWaiting writes the pre-acquire line of a Lock or RLock that finds the lock
+// taken, so a blocked goroutine shows up in the log before it acquires.
+// The line after the acquisition is written by count as for any other lock.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) logWaiting(write bool) {
+	flag := m.DebugRLock
+	blocks := m.lockedCount > 0 || m.writerWaiters > 0 // pending writers block new readers
+	if write {
+		flag = m.DebugLock
+		blocks = m.lockedCount > 0 || m.rLockedCount > 0
+	}
+	if !blocks || !m.debug(flag) || JSONLines || m.JSONLines {
+		return
+	}
+	m.logOp(lockOp(write), 0, m.nameField(), "waiting", fmt.Sprintf("locked=%d", m.lockedCount), fmt.Sprintf("rLocked=%d", m.rLockedCount))
+}
 
-		m.mu.Lock()
+// waitEnded removes a goroutine counted by prepare from the waiters.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) waitEnded(write bool) {
+	if write {
+		m.writerWaiters--
+	} else {
+		m.readerWaiters--
+	}
+}
+
+// waiters returns the number of goroutines in Lock or RLock that have not acquired yet.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) waiters() counter {
+	return m.readerWaiters + m.writerWaiters
+}
+
+// abandoned undoes prepare for an acquisition that gave up.
+func (m *LoggedSyncRWMutex) abandoned(st acquireState, write bool) {
+	if !st.enabled {
+		return
+	}
+	m.mu.Lock()
+	m.waitEnded(write)
+	m.mu.Unlock()
+}
+
+// acquired counts and logs a lock or read lock
+// and records ownership and timing after the embedded lock has been acquired.
+func (m *LoggedSyncRWMutex) acquired(st acquireState, write bool) {
+	if !st.enabled {
+		return
+	}
+	if st.race {
+		m.raceAcquired(write)
+	}
+	if st.baseMu != nil {
+		m.baselineAcquired(st.baseMu, st.opStart, write)
+	}
+	var t time.Time
+	if st.sampled || !st.waitStart.IsZero() {
+		t = now()
+	}
+	if beat, stats := m.count(st, t, write); beat != nil {
+		beat(stats)
+	}
+	if hook := OnFirstUse; hook != nil {
+		m.firstUseOnce.Do(func() { hook(m) })
+	}
+}
+
+// count is the part of acquired running under m.mu,
+// it returns the OnHeartbeat callback if due.
+// Like every section under m.mu it releases m.mu by defer.
+func (m *LoggedSyncRWMutex) count(st acquireState, t time.Time, write bool) (beat func(Stats), stats Stats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.waitEnded(write)
+	wait := time.Duration(-1)
+	if !st.waitStart.IsZero() {
+		wait = t.Sub(st.waitStart)
+	}
+	if write {
+		if m.StrictInvariants && m.lockedCount > 0 {
+			m.misuse(MisuseInvariant, opLock, "write lock acquired with locked=%d, the counters are corrupt", m.lockedCount)
+		}
+		m.lockedCount++
+		m.totalLocked++
+		m.label = st.label
+		m.addWeight(st.weight)
+		m.record(opLock, st.gid, wait)
+	} else {
 		m.rLockedCount++
 		m.totalrLocked++
-		if m.DebugRLock || m.DebugAll || GlobalDebug {
-			fmt.Printf("[loggedMUTEX] RLock '%s' rLocked=%d/%d\n", m.Name, m.rLockedCount, m.totalrLocked)
+		m.readerSample()
+		m.record(opRLock, st.gid, wait)
+	}
+	if st.ordered {
+		orderAcquired(st.gid, m, m.Name)
+	}
+	if st.track {
+		m.ownerAcquired(st.gid, write)
+	}
+	if m.CountGoroutines && st.gid != 0 {
+		m.goroutineSeen(st.gid)
+	}
+	if wait >= 0 {
+		m.waitDone(wait, st.gid)
+	}
+	if m.PairedLogging {
+		m.pairedAcquired(write, wait)
+	}
+	if st.contended {
+		m.feat().contendedCount++
+	} else if st.uncontended {
+		m.feat().uncontendedCount++
+	}
+	if m.RecordUse {
+		m.used(t)
+	}
+	if st.hold {
+		m.holdStarted(t, write, st.sampled)
+	}
+	// frames: count, acquired, Lock/RLock/LockCtx/RLockCtx
+	m.sampleCaller(3)
+	var depth int
+	if IndentByDepth && st.gid != 0 {
+		depth = depthAcquired(st.gid)
+	}
+	paired := m.paired() // logged on release
+	if write {
+		if !paired && m.debug(m.DebugLock) {
+			m.logOp(opLock, depth, m.nameField(), labelField(st.label), m.lockedField(m.totalLocked), idField(st.id))
+		}
+	} else {
+		if !paired && m.debug(m.DebugRLock) {
+			m.logOp(opRLock, depth, m.nameField(), fmt.Sprintf("rLocked=%d/%d", m.rLockedCount, m.totalrLocked), idField(st.id))
 		}
-		m.mu.Unlock()
 	}
-	m.RWMutex.RLock()
+	m.gcSync()
+	m.verify(lockOp(write))
+	return m.heartbeat()
 }
 
-func (m *LoggedSyncRWMutex) RUnlock() {
-	m.RWMutex.RUnlock()
+// releaseState carries what has to be recorded once the embedded lock is released.
+type releaseState struct {
+	timed    bool                             // time the embedded release
+	baseMu   *sync.RWMutex                    // baseline mutex released along with the lock, nil if none
+	start    time.Time                        // start of the operation if baseMu is set
+	baseline time.Duration                    // duration of the baseline release
+	onHold   func(op string, d time.Duration) // OnHold to call with hold
+	op       string                           // op for onHold
+	hold     time.Duration                    // measured hold duration for onHold
+}
 
-	if !DisableLogging {
-		m.mu.Lock()
+// releasing counts and logs an unlock or read unlock
+// and records ownership and timing before the embedded lock is released.
+// It returns what has to be recorded after the release.
+func (m *LoggedSyncRWMutex) releasing(write bool) (rs releaseState) {
+	if m.disabled() {
+		return
+	}
+	defer func() {
+		if rs.baseMu != nil {
+			rs.baseline = baselineRelease(rs.baseMu, write)
+		}
+	}()
+	var gid int64
+	ordered := orderActive.Load()
+	if ordered || IndentByDepth {
+		gid = goid()
+	}
+	t := now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.StrictOwnership {
+		if gid == 0 {
+			gid = goid()
+		}
+		m.checkStrictOwner(gid, write)
+	}
+	if ordered {
+		orderReleased(gid, m)
+	}
+	if m.RaceAnnotations {
+		m.raceReleasing(write)
+	}
+	rs.baseMu, rs.start = m.baselineReleasing(write), t
+	if m.RecordUse {
+		m.used(t)
+	}
+	if m.tracksOwners() {
+		if gid == 0 {
+			gid = goid()
+		}
+		m.ownerReleased(gid, write)
+	}
+	var hold time.Duration
+	var held bool
+	if m.MeasureHold {
+		hold, held = m.holdDone(t, write)
+		if held && m.DetectInversion {
+			m.checkInversion(hold)
+		}
+		if held && m.OnHold != nil {
+			rs.onHold, rs.hold = m.OnHold, hold
+			rs.op = opNames[opRUnlock]
+			if write {
+				rs.op = opNames[opUnlock]
+			}
+		}
+	}
+	rs.timed = m.MeasureHold
+	var depth int
+	if IndentByDepth {
+		depth = depthReleased(gid)
+	}
+	paired := m.paired()
+	if m.PairedLogging {
+		m.pairedReleased(write, depth, hold, held)
+	}
+	if write {
+		m.lockedCount--
+		m.totalUnlocked++
+		m.record(opUnlock, gid, hold)
+		if !paired && m.debug(m.DebugUnlock) {
+			m.logOp(opUnlock, depth, m.nameField(), labelField(m.label), m.lockedField(m.totalUnlocked))
+		}
+		m.label = ""
+	} else {
 		m.rLockedCount--
 		m.totalrUnlocked++
-		if m.DebugRUnlock || m.DebugAll || GlobalDebug {
-			fmt.Printf("[loggedMUTEX] RUnlock '%s' rLockedCount=%d/%d\n", m.Name, m.rLockedCount, m.totalrUnlocked)
+		m.record(opRUnlock, gid, hold)
+		if !paired && m.debug(m.DebugRUnlock) {
+			m.logOp(opRUnlock, depth, m.nameField(), fmt.Sprintf("rLockedCount=%d/%d", m.rLockedCount, m.totalrUnlocked))
 		}
-		m.mu.Unlock()
+	}
+	m.gcSync()
+	m.verify(unlockOp(write))
+	return
+}
+
+// released records the timing of the embedded release which started at start
+// and calls OnHold outside of m.mu.
+func (m *LoggedSyncRWMutex) released(rs releaseState, start time.Time) {
+	if !rs.timed && rs.baseMu == nil {
+		return
+	}
+	m.mu.Lock()
+	if rs.timed {
+		m.unlockDone(now().Sub(start))
+	}
+	if rs.baseMu != nil {
+		m.baselineReleased(rs)
+	}
+	m.mu.Unlock()
+	if rs.onHold != nil {
+		rs.onHold(rs.op, rs.hold)
 	}
 }
@@ -2,6 +2,7 @@ package loggedrwmutex
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -51,8 +52,8 @@ func TestLoggedSyncRWMutex(t *testing.T) {
 		mux.Lock()
 		lockHeld = true
 		time.Sleep(50 * time.Millisecond)
-		mux.Unlock()
 		lockHeld = false
+		mux.Unlock()
 	}()
 
 	time.Sleep(10 * time.Millisecond) // Give the lock goroutine a chance to start
@@ -101,3 +102,157 @@ func TestLoggedSyncRWMutex(t *testing.T) {
 
 	fmt.Println("quit")
 }
+
+func TestPrintStatus(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestPrintStatus"}
+	mux.Lock()
+	mux.PrintStatus(false)
+	mux.Unlock()
+	mux.PrintStatus(false)
+	mux.PrintStatus(true)
+
+	want := "?? [loggedMUTEX] Status 'TestPrintStatus' locked=1, rLocked=0 totalLocked/totalUnlocked=1/0 totalrLocked/totalrUnlocked=0/0\n" +
+		"?? [loggedMUTEX] Status 'TestPrintStatus' locked=0, rLocked=0 totalLocked/totalUnlocked=1/1 totalrLocked/totalrUnlocked=0/0\n"
+	if got := buf.String(); got != want {
+		t.Errorf("PrintStatus should write one line per held or forced status, want %q, got %q", want, got)
+	}
+}
+
+func TestForceLogging(t *testing.T) {
+	buf := captureOutput(t)
+	DisableLogging = true
+	defer func() { DisableLogging = false }()
+
+	plain := &LoggedSyncRWMutex{Name: "TestForceLoggingPlain", DebugAll: true}
+	forced := &LoggedSyncRWMutex{Name: "TestForceLogging", DebugAll: true, ForceLogging: true}
+	for _, mux := range []*LoggedSyncRWMutex{plain, forced} {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RUnlock()
+	}
+
+	if st := plain.Snapshot(); st.TotalLocked != 0 || st.TotalRLocked != 0 {
+		t.Errorf("mutex without ForceLogging should not count, got %+v", st)
+	}
+	if st := forced.Snapshot(); st.TotalLocked != 1 || st.TotalUnlocked != 1 || st.TotalRLocked != 1 || st.TotalRUnlocked != 1 {
+		t.Errorf("mutex with ForceLogging should count, got %+v", st)
+	}
+	got := lines(buf)
+	if len(got) != 4 {
+		t.Fatalf("should log 4 lines, got %q", got)
+	}
+	for _, line := range got {
+		if !strings.Contains(line, "'TestForceLogging'") {
+			t.Errorf("only the forced mutex should log, got %q", line)
+		}
+	}
+}
+
+func TestLockLabeled(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestLockLabeled", DebugAll: true}
+
+	mux.LockLabeled("flush")
+	mux.Unlock()
+	mux.Lock()
+	mux.Unlock()
+
+	got := lines(buf)
+	want := []string{
+		"[loggedMUTEX] Lock 'TestLockLabeled' (flush) locked=1/1",
+		"[loggedMUTEX] Unlock 'TestLockLabeled' (flush) locked=0/1",
+		"[loggedMUTEX] Lock 'TestLockLabeled' locked=1/2",
+		"[loggedMUTEX] Unlock 'TestLockLabeled' locked=0/2",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("should log %d lines, got %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+// panicWriter panics on every write.
+type panicWriter struct{}
+
+func (panicWriter) Write(p []byte) (int, error) { panic("write failed") }
+
+// recovered calls fn and returns the recovered panic value.
+func recovered(fn func()) (p any) {
+	defer func() { p = recover() }()
+	fn()
+	return nil
+}
+
+func TestPanicWhileStateLocked(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestPanicWhileStateLocked", DebugAll: true, Writer: panicWriter{}}
+	mux.EnableBinaryTrace(panicWriter{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if p := recovered(mux.Lock); p != nil {
+			t.Errorf("Lock should not pass on the panic of the writer, got %v", p)
+		}
+		if p := recovered(mux.Unlock); p != nil {
+			t.Errorf("Unlock should not pass on the panic of the writer, got %v", p)
+		}
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("operations with a panicking writer deadlocked")
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 1 || st.TotalUnlocked != 1 || st.TotalRLocked != 1 || st.RLocked != 0 || st.WriteErrors != 4 {
+		t.Errorf("counters should reflect all operations, got %+v", st)
+	}
+
+	// a panicking heartbeat runs outside of the state lock
+	mux.HeartbeatEvery = 1
+	mux.OnHeartbeat = func(Stats) { panic("heartbeat failed") }
+	if p := recovered(mux.RLock); p == nil {
+		t.Fatal("RLock should pass on the panic of OnHeartbeat")
+	}
+	mux.OnHeartbeat = nil
+	mux.RUnlock()
+	if st := mux.Snapshot(); st.RLocked != 0 {
+		t.Errorf("RUnlock after a panicking heartbeat should release, got %+v", st)
+	}
+}
+
+func TestRLockPolite(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestRLockPolite"}
+	if !mux.RLockPolite() {
+		t.Fatal("RLockPolite should acquire without a queued writer")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Lock()
+		mux.Unlock()
+	}()
+	for mux.Snapshot().Waiters != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if mux.RLockPolite() {
+		t.Error("RLockPolite should decline while a writer is queued")
+		mux.RUnlock()
+	}
+	mux.RUnlock()
+	<-done
+
+	if !mux.RLockPolite() {
+		t.Fatal("RLockPolite should acquire once the writer is done")
+	}
+	mux.RUnlock()
+	if st := mux.Snapshot(); st.TotalRLocked != 2 || st.RLocked != 0 || st.Waiters != 0 {
+		t.Errorf("only the acquired read locks should be counted, got %+v", st)
+	}
+}
@@ -0,0 +1,65 @@
+// Package loggedrwmutextest provides utilities to validate the usage of a
+// loggedrwmutex.LoggedSyncRWMutex under concurrency, best run with the race detector.
+package loggedrwmutextest
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-while/go-loggedrwmutex"
+)
+
+// Stress runs readers and writers goroutines doing iterations lock/unlock cycles each on m.
+// It returns an error if a writer ever shared the lock with another holder,
+// if the locks and unlocks during the run are not balanced,
+// or if a lock is still held afterwards.
+func Stress(m *loggedrwmutex.LoggedSyncRWMutex, readers, writers, iterations int) error {
+	var active, writing atomic.Int64
+	var violations atomic.Int64
+	err := m.CheckBalanced(func() {
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					m.Lock()
+					if active.Add(1) != 1 {
+						violations.Add(1)
+					}
+					writing.Store(1)
+					writing.Store(0)
+					active.Add(-1)
+					m.Unlock()
+				}
+			}()
+		}
+		for i := 0; i < readers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < iterations; j++ {
+					m.RLock()
+					active.Add(1)
+					if writing.Load() != 0 {
+						violations.Add(1)
+					}
+					active.Add(-1)
+					m.RUnlock()
+				}
+			}()
+		}
+		wg.Wait()
+	})
+	if err != nil {
+		return err
+	}
+	if n := violations.Load(); n > 0 {
+		return fmt.Errorf("loggedrwmutextest: '%s' write lock shared %d times", m.Name, n)
+	}
+	if st := m.Snapshot(); st.Locked != 0 || st.RLocked != 0 {
+		return fmt.Errorf("loggedrwmutextest: '%s' still held after stress: locked=%d rLocked=%d", m.Name, st.Locked, st.RLocked)
+	}
+	return nil
+}
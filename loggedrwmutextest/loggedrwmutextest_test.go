@@ -0,0 +1,27 @@
+package loggedrwmutextest
+
+import (
+	"testing"
+
+	"github.com/go-while/go-loggedrwmutex"
+)
+
+func TestStress(t *testing.T) {
+	mux := &loggedrwmutex.LoggedSyncRWMutex{Name: "TestStress", TrackOwnership: true, MeasureHold: true}
+	if err := Stress(mux, 8, 4, 200); err != nil {
+		t.Fatalf("Stress should complete cleanly, got %v", err)
+	}
+	st := mux.Snapshot()
+	if st.TotalLocked != 4*200 || st.TotalRLocked != 8*200 {
+		t.Errorf("Stress should run all iterations, got %+v", st)
+	}
+}
+
+func TestStressLeak(t *testing.T) {
+	mux := &loggedrwmutex.LoggedSyncRWMutex{Name: "TestStressLeak"}
+	mux.RLock() // leaked, readers only as writers would block forever
+	if err := Stress(mux, 2, 0, 10); err == nil {
+		t.Error("Stress should report the held read lock")
+	}
+	mux.RUnlock()
+}
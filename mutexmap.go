@@ -0,0 +1,150 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// LoggedMutexMap is a set of logged mutexes keyed by id, e.g. one lock per account,
+// created on first use. The zero value is ready to use.
+//
+//	accounts := &loggedrwmutex.LoggedMutexMap{Name: "account", Configure: func(m *loggedrwmutex.LoggedSyncRWMutex) { m.DebugAll = true }}
+//	accounts.Lock(id)
+//	defer accounts.Unlock(id)
+type LoggedMutexMap struct {
+	Name      string                     // the mutex of key is named Name/key
+	Configure func(m *LoggedSyncRWMutex) // if set, called on every new mutex before its first use for shared config
+	MaxKeys   int                        // number of mutexes above which unused ones are dropped, 0 uses MaxTrackedEntries
+
+	mu      sync.Mutex
+	entries map[string]*mapEntry
+	retired []Stats // merged stats of dropped mutexes, empty before the first drop
+}
+
+// mapEntry is one mutex of a LoggedMutexMap.
+type mapEntry struct {
+	m    *LoggedSyncRWMutex
+	refs int // pending and held locks, the entry is only dropped at 0
+}
+
+// Lock locks the mutex of key.
+func (mm *LoggedMutexMap) Lock(key string) {
+	mm.acquire(key).Lock()
+}
+
+// Unlock unlocks the mutex of key.
+func (mm *LoggedMutexMap) Unlock(key string) {
+	mm.lookup(key, "Unlock").Unlock()
+	mm.release(key)
+}
+
+// RLock acquires a read lock on the mutex of key.
+func (mm *LoggedMutexMap) RLock(key string) {
+	mm.acquire(key).RLock()
+}
+
+// RUnlock releases a read lock on the mutex of key.
+func (mm *LoggedMutexMap) RUnlock(key string) {
+	mm.lookup(key, "RUnlock").RUnlock()
+	mm.release(key)
+}
+
+// acquire returns the mutex of key, created if needed, and references it until release.
+func (mm *LoggedMutexMap) acquire(key string) *LoggedSyncRWMutex {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	e := mm.entries[key]
+	if e == nil {
+		if mm.entries == nil {
+			mm.entries = make(map[string]*mapEntry)
+		}
+		mm.drop()
+		e = &mapEntry{m: &LoggedSyncRWMutex{Name: mm.Name + "/" + key}}
+		if mm.Configure != nil {
+			mm.Configure(e.m)
+		}
+		mm.entries[key] = e
+	}
+	e.refs++
+	return e.m
+}
+
+// lookup returns the mutex of key for an unlock op.
+func (mm *LoggedMutexMap) lookup(key string, op string) *LoggedSyncRWMutex {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	e := mm.entries[key]
+	if e == nil {
+		panic(fmt.Sprintf("[loggedMUTEX] '%s' %s of unknown key '%s'", NamePrefix+mm.Name, op, key))
+	}
+	return e.m
+}
+
+// release drops the reference of a finished lock on key.
+func (mm *LoggedMutexMap) release(key string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.entries[key].refs--
+}
+
+// drop removes unreferenced mutexes while the map is at MaxKeys, keeping their stats.
+// Referenced mutexes are kept, so the map only grows beyond MaxKeys
+// while more keys are in use at the same time.
+// Must be called with mm.mu held.
+func (mm *LoggedMutexMap) drop() {
+	limit := mm.MaxKeys
+	if limit <= 0 {
+		limit = MaxTrackedEntries
+	}
+	if limit <= 0 {
+		return
+	}
+	for key, e := range mm.entries {
+		if len(mm.entries) < limit {
+			return
+		}
+		if e.refs == 0 {
+			mm.retired = []Stats{MergeStats(append(mm.retired, e.m.Snapshot())...)}
+			delete(mm.entries, key)
+		}
+	}
+}
+
+// Keys returns the sorted keys that currently have a mutex.
+func (mm *LoggedMutexMap) Keys() []string {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	keys := make([]string, 0, len(mm.entries))
+	for key := range mm.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// KeyStats returns the stats of the mutex of key, false if it has none.
+func (mm *LoggedMutexMap) KeyStats(key string) (Stats, bool) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	e := mm.entries[key]
+	if e == nil {
+		return Stats{}, false
+	}
+	return e.m.Snapshot(), true
+}
+
+// Snapshot returns the stats of all keys merged with MergeStats,
+// including the mutexes dropped for MaxKeys, named Name.
+func (mm *LoggedMutexMap) Snapshot() Stats {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	stats := slices.Clone(mm.retired)
+	for _, e := range mm.entries {
+		stats = append(stats, e.m.Snapshot())
+	}
+	sum := MergeStats(stats...)
+	sum.Name = mm.Name
+	return sum
+}
@@ -0,0 +1,68 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestLoggedMutexMap(t *testing.T) {
+	configured := 0
+	mm := &LoggedMutexMap{Name: "TestMutexMap", Configure: func(m *LoggedSyncRWMutex) {
+		configured++
+		m.Group = "accounts"
+	}}
+	keys := []string{"a", "b", "c"}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 25; j++ {
+					mm.Lock(key)
+					mm.Unlock(key)
+					mm.RLock(key)
+					mm.RUnlock(key)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	if got := mm.Keys(); !slices.Equal(got, keys) || configured != 3 {
+		t.Errorf("Keys should be %q each configured once, got %q configured %d times", keys, got, configured)
+	}
+	for _, key := range keys {
+		st, ok := mm.KeyStats(key)
+		if !ok || st.Name != "TestMutexMap/"+key || st.Group != "accounts" || st.TotalLocked != 100 || st.TotalRUnlocked != 100 {
+			t.Errorf("key %q should have 100 locks and read locks, got %+v", key, st)
+		}
+	}
+	if _, ok := mm.KeyStats("unknown"); ok {
+		t.Error("KeyStats of an unknown key should report false")
+	}
+	if st := mm.Snapshot(); st.Name != "TestMutexMap" || st.Group != "accounts" || st.TotalLocked != 300 || st.TotalUnlocked != 300 || st.TotalRLocked != 300 {
+		t.Errorf("Snapshot should aggregate 300 locks, got %+v", st)
+	}
+	mustPanic(t, "Unlock of an unknown key", func() { mm.Unlock("unknown") })
+}
+
+func TestLoggedMutexMapMaxKeys(t *testing.T) {
+	mm := &LoggedMutexMap{Name: "TestMutexMapMaxKeys", MaxKeys: 2}
+	mm.Lock("held")
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprint(i)
+		mm.Lock(key)
+		mm.Unlock(key)
+	}
+	keys := mm.Keys()
+	if len(keys) != 2 || !slices.Contains(keys, "held") {
+		t.Errorf("map should keep 2 keys including the held one, got %q", keys)
+	}
+	mm.Unlock("held")
+	if st := mm.Snapshot(); st.TotalLocked != 6 || st.TotalUnlocked != 6 {
+		t.Errorf("Snapshot should include dropped mutexes, got %+v", st)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !race
+
+package loggedrwmutex
+
+// raceEnabled reports whether the package is built with the race detector.
+const raceEnabled = false
+
+// raceAcquired is a no-op without the race detector.
+func (m *LoggedSyncRWMutex) raceAcquired(write bool) {}
+
+// raceReleasing is a no-op without the race detector.
+func (m *LoggedSyncRWMutex) raceReleasing(write bool) {}
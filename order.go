@@ -0,0 +1,95 @@
+package loggedrwmutex
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	orderMu     sync.Mutex
+	orderRules  map[string][]string                         // first -> names that must not be held while acquiring first
+	orderHeld   map[int64]map[*LoggedSyncRWMutex]*orderHold // goroutine id -> held mutexes
+	orderActive atomic.Bool                                 // set once the first rule is registered
+)
+
+// orderHold is a mutex held by a goroutine, name is its Name at the first
+// acquisition so a SetName while held does not leave a stale entry behind.
+type orderHold struct {
+	name  string
+	depth int
+}
+
+// EnforceOrder registers the rule that the mutex named first is always acquired
+// before the mutex named second. A goroutine holding second that locks first
+// is reported as misuse (see PanicOnMisuse).
+// Once a rule exists, every logged mutex tracks the names held per goroutine.
+func EnforceOrder(first, second string) {
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	if orderRules == nil {
+		orderRules = make(map[string][]string)
+		orderHeld = make(map[int64]map[*LoggedSyncRWMutex]*orderHold)
+	}
+	orderRules[first] = append(orderRules[first], second)
+	orderActive.Store(true)
+}
+
+// checkOrder reports a violation of the registered order rules by op
+// if goroutine gid holds a mutex that must be acquired after this one.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) checkOrder(gid int64, op byte) {
+	orderMu.Lock()
+	var violated string
+	held := orderHeld[gid]
+rules:
+	for _, second := range orderRules[m.Name] {
+		for _, h := range held {
+			if h.name == second {
+				violated = second
+				break rules
+			}
+		}
+	}
+	orderMu.Unlock()
+	if violated != "" {
+		m.misuse(MisuseOrder, op, "goroutine %d acquires '%s' while holding '%s', must be acquired before it", gid, m.Name, violated)
+	}
+}
+
+// orderAcquired records that goroutine gid holds m under name.
+func orderAcquired(gid int64, m *LoggedSyncRWMutex, name string) {
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	held := orderHeld[gid]
+	if held == nil {
+		held = make(map[*LoggedSyncRWMutex]*orderHold)
+		orderHeld[gid] = held
+	}
+	h := held[m]
+	if h == nil {
+		h = &orderHold{name: name}
+		held[m] = h
+	}
+	h.depth++
+}
+
+// orderReleased records that goroutine gid released m and returns the name
+// m was recorded under, empty if gid does not hold it.
+func orderReleased(gid int64, m *LoggedSyncRWMutex) string {
+	orderMu.Lock()
+	defer orderMu.Unlock()
+	held := orderHeld[gid]
+	h := held[m]
+	if h == nil {
+		return ""
+	}
+	if h.depth > 1 {
+		h.depth--
+		return h.name
+	}
+	delete(held, m)
+	if len(held) == 0 {
+		delete(orderHeld, gid)
+	}
+	return h.name
+}
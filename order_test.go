@@ -0,0 +1,101 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"testing"
+)
+
+// resetOrder removes all EnforceOrder rules when the test ends.
+func resetOrder(t *testing.T) {
+	t.Cleanup(func() {
+		orderMu.Lock()
+		orderRules, orderHeld = nil, nil
+		orderActive.Store(false)
+		orderMu.Unlock()
+	})
+}
+
+func TestEnforceOrder(t *testing.T) {
+	resetOrder(t)
+	buf := captureWarnings(t)
+	a := &LoggedSyncRWMutex{Name: "OrderA"}
+	b := &LoggedSyncRWMutex{Name: "OrderB"}
+	EnforceOrder("OrderA", "OrderB")
+
+	// correct order
+	a.Lock()
+	b.Lock()
+	b.Unlock()
+	a.Unlock()
+	if buf.Len() != 0 {
+		t.Errorf("correct order should not warn, got %q", buf.String())
+	}
+
+	// violation, also for read locks
+	b.RLock()
+	a.Lock()
+	a.Unlock()
+	b.RUnlock()
+	got := lines(buf)
+	if len(got) != 1 || !strings.Contains(got[0], "acquires 'OrderA' while holding 'OrderB'") {
+		t.Errorf("violation should be reported once, got %q", got)
+	}
+
+	// held by another goroutine is no violation
+	buf.Reset()
+	b.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.Lock()
+		a.Unlock()
+	}()
+	<-done
+	b.Unlock()
+	if buf.Len() != 0 {
+		t.Errorf("lock held by another goroutine should not warn, got %q", buf.String())
+	}
+}
+
+func TestEnforceOrderPanic(t *testing.T) {
+	resetOrder(t)
+	PanicOnMisuse = true
+	defer func() { PanicOnMisuse = false }()
+	a := &LoggedSyncRWMutex{Name: "OrderPanicA"}
+	b := &LoggedSyncRWMutex{Name: "OrderPanicB"}
+	EnforceOrder("OrderPanicA", "OrderPanicB")
+
+	b.Lock()
+	mustPanic(t, "order violation", a.Lock)
+	b.Unlock()
+	if a.totalLocked != 0 {
+		t.Errorf("panicked Lock should not be counted, got %d", a.totalLocked)
+	}
+	// a is still usable
+	a.Lock()
+	a.Unlock()
+}
+
+func TestEnforceOrderSetName(t *testing.T) {
+	resetOrder(t)
+	buf := captureWarnings(t)
+	a := &LoggedSyncRWMutex{Name: "OrderRenameA"}
+	b := &LoggedSyncRWMutex{Name: "OrderRenameB"}
+	EnforceOrder("OrderRenameA", "OrderRenameB")
+
+	// renamed while held, the release must remove the name of the acquisition
+	b.Lock()
+	b.SetName("OrderRenameC")
+	b.Unlock()
+	a.Lock()
+	a.Unlock()
+	if buf.Len() != 0 {
+		t.Errorf("released mutex should not be held under its old name, got %q", buf.String())
+	}
+	orderMu.Lock()
+	n := len(orderHeld)
+	orderMu.Unlock()
+	if n != 0 {
+		t.Errorf("no goroutine should hold a mutex, got %d", n)
+	}
+}
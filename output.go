@@ -0,0 +1,206 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RelativeTimestamps prefixes log lines with the elapsed time since the package was initialized, e.g. "+1234ms".
+var RelativeTimestamps = false
+
+// processStart is the reference for RelativeTimestamps.
+var processStart = time.Now()
+
+// loggingPaused gates the emission of log lines, counting continues.
+var loggingPaused atomic.Bool
+
+// PauseLogging suppresses all lock log lines until ResumeLogging is called,
+// e.g. during a noisy startup. Unlike DisableLogging all counters keep counting
+// and it can be toggled at any time.
+func PauseLogging() {
+	loggingPaused.Store(true)
+}
+
+// ResumeLogging re-enables log lines suppressed by PauseLogging.
+func ResumeLogging() {
+	loggingPaused.Store(false)
+}
+
+// WithoutLogging runs fn with the log lines of this mutex suppressed, counting continues.
+// The previous state is restored when fn returns or panics, so calls may be nested.
+// Operations of other goroutines during fn are not logged either.
+func (m *LoggedSyncRWMutex) WithoutLogging(fn func()) {
+	m.mu.Lock()
+	prev := m.withoutLogging
+	m.withoutLogging = true
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.withoutLogging = prev
+		m.mu.Unlock()
+	}()
+	fn()
+}
+
+// output returns the writer for the log lines of m.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) output() io.Writer {
+	if m.Writer != nil {
+		return m.Writer
+	}
+	return Output
+}
+
+// MaxWriteErrors is the number of failed writes after which a mutex stops logging,
+// e.g. when its output is a broken pipe. 0 never stops.
+var MaxWriteErrors uint64 = 10
+
+// logf writes one log line to the output of m unless logging is paused, suspended
+// by WithoutLogging or has been stopped after MaxWriteErrors failed writes.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) logf(format string, args ...any) {
+	if m.withoutLogging {
+		return
+	}
+	m.writeDone(m.writef(m.output(), format, args...))
+}
+
+// writeDone counts a failed write of a log line and warns when it stops logging.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) writeDone(err error) {
+	if msg := m.writeFailed(err); msg != "" {
+		m.warnf(SeverityWarn, "write-error", "%s", msg)
+	}
+}
+
+// safeFprintf writes to w like fmt.Fprintf and turns a panic of w into an error.
+// Writers are called with m.mu held, a panic must not skip the rest of an operation
+// such as the embedded Unlock.
+func safeFprintf(w io.Writer, format string, args ...any) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("loggedrwmutex: writer panicked: %v", p)
+		}
+	}()
+	_, err = fmt.Fprintf(w, format, args...)
+	return err
+}
+
+// safeWrite writes p to w and turns a panic of w into an error, see safeFprintf.
+func safeWrite(w io.Writer, p []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loggedrwmutex: writer panicked: %v", r)
+		}
+	}()
+	_, err = w.Write(p)
+	return err
+}
+
+// opLetters are the op codes written with CompactOps.
+var opLetters = map[byte]string{
+	opLock:    "[L]",
+	opUnlock:  "[U]",
+	opRLock:   "[r]",
+	opRUnlock: "[u]",
+}
+
+// FieldSeparator joins the fields of operation log lines: the op code with CompactOps,
+// [loggedMUTEX], the op word, the quoted name, an optional (label) and key=value fields.
+// The Status lines of DumpAll and the Rate lines of StartRateReporter are joined by it too.
+// PrintStatus keeps its original format, and warnings, "[loggedMUTEX] SEVERITY 'name' kind: message"
+// with a free text message, are not split into fields.
+var FieldSeparator = " "
+
+// VersionTag is appended to every operation log line as ver=<tag> if not empty,
+// e.g. a build version to tell apart the logs of several deployments.
+var VersionTag string
+
+// logOp writes the log line of one operation, fields follow the op word and empty ones are skipped.
+// depth is the number of other locks held by the goroutine for IndentByDepth.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) logOp(op byte, depth int, fields ...string) {
+	if JSONLines || m.JSONLines {
+		if m.logAllowed() {
+			m.logJSON(op)
+		}
+		return
+	}
+	if m.LogGaps {
+		t, f := now(), m.feat()
+		if last := f.lastLogged[op]; !last.IsZero() {
+			fields = append(fields, "gap="+t.Sub(last).String())
+		}
+		f.lastLogged[op] = t
+	}
+	if m.LogStackDepth {
+		fields = append(fields, "depth="+strconv.Itoa(callerDepth()))
+	}
+	line := opLine(op, depth, m.CompactOps, m.CompactOpsOnly, fields)
+	if m.DedupeConsecutive && m.dedupe(line) {
+		return
+	}
+	if !m.logAllowed() {
+		return
+	}
+	m.logf("%s\n", line)
+}
+
+// opLine formats the log line of one operation: the op code with compact,
+// [loggedMUTEX], the op word unless compactOnly, the non-empty fields and
+// VersionTag, indented by depth with IndentByDepth.
+func opLine(op byte, depth int, compact, compactOnly bool, fields []string) string {
+	parts := make([]string, 0, len(fields)+4)
+	if compact {
+		parts = append(parts, opLetters[op])
+	}
+	parts = append(parts, "[loggedMUTEX]")
+	if !compact || !compactOnly {
+		parts = append(parts, opNames[op])
+	}
+	for _, f := range fields {
+		if f != "" {
+			parts = append(parts, f)
+		}
+	}
+	if VersionTag != "" {
+		parts = append(parts, "ver="+VersionTag)
+	}
+	line := strings.Join(parts, FieldSeparator)
+	if IndentByDepth {
+		line = indent(depth) + line
+	}
+	return line
+}
+
+// nameField formats the quoted name of m as log field.
+func (m *LoggedSyncRWMutex) nameField() string {
+	return "'" + m.logName() + "'"
+}
+
+// logName returns the Name used in log output.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) logName() string {
+	return NamePrefix + m.Name
+}
+
+// LogName returns the Name used in log output, NamePrefix included.
+// Unlike reading Name it is safe while SetName runs.
+func (m *LoggedSyncRWMutex) LogName() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.logName()
+}
+
+// FloatPrecision is the number of decimal places of float values (rates, ratios)
+// in log and report output, -1 uses the shortest exact representation.
+var FloatPrecision = 2
+
+// formatFloat formats v according to FloatPrecision.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', FloatPrecision, 64)
+}
@@ -0,0 +1,331 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPauseLogging(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestPauseLogging", DebugAll: true}
+
+	PauseLogging()
+	defer ResumeLogging()
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	if buf.Len() != 0 {
+		t.Errorf("no lines should be emitted while paused, got %q", buf.String())
+	}
+	if mux.totalLocked != 1 || mux.totalUnlocked != 1 || mux.totalrLocked != 1 || mux.totalrUnlocked != 1 {
+		t.Errorf("counters should advance while paused, got %d/%d %d/%d", mux.totalLocked, mux.totalUnlocked, mux.totalrLocked, mux.totalrUnlocked)
+	}
+
+	ResumeLogging()
+	mux.Lock()
+	mux.Unlock()
+	if got := lines(buf); len(got) != 2 {
+		t.Errorf("lines should resume after ResumeLogging, got %q", got)
+	}
+}
+
+func TestWithoutLogging(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestWithoutLogging", DebugAll: true}
+
+	mux.WithoutLogging(func() {
+		mux.Lock()
+		mux.Unlock()
+		mux.WithoutLogging(func() {
+			mux.RLock()
+			mux.RUnlock()
+		})
+		// still suppressed after the nested call
+		mux.Lock()
+		mux.Unlock()
+	})
+	if buf.Len() != 0 {
+		t.Errorf("no lines should be emitted during fn, got %q", buf.String())
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 2 || st.TotalUnlocked != 2 || st.TotalRLocked != 1 || st.TotalRUnlocked != 1 {
+		t.Errorf("counters should advance during fn, got %+v", st)
+	}
+	mux.Lock()
+	mux.Unlock()
+	if got := lines(buf); len(got) != 2 {
+		t.Errorf("lines should resume after WithoutLogging, got %q", got)
+	}
+
+	// JSON lines are suppressed as well
+	buf.Reset()
+	mux.JSONLines = true
+	mux.WithoutLogging(func() {
+		mux.Lock()
+		mux.Unlock()
+	})
+	mux.JSONLines = false
+	if buf.Len() != 0 {
+		t.Errorf("no JSON lines should be emitted during fn, got %q", buf.String())
+	}
+
+	// restored when fn panics
+	buf.Reset()
+	recovered(func() { mux.WithoutLogging(func() { panic("boom") }) })
+	mux.Lock()
+	mux.Unlock()
+	if got := lines(buf); len(got) != 2 {
+		t.Errorf("lines should resume after a panic in fn, got %q", got)
+	}
+}
+
+func TestNamePrefix(t *testing.T) {
+	buf := captureOutput(t)
+	NamePrefix = "db/"
+	defer func() { NamePrefix = "" }()
+	mux := &LoggedSyncRWMutex{Name: "TestNamePrefix", DebugAll: true}
+	mux.Lock()
+	mux.Unlock()
+
+	for _, line := range lines(buf) {
+		if !strings.Contains(line, "'db/TestNamePrefix'") {
+			t.Errorf("log line should contain the prefixed name: %q", line)
+		}
+	}
+	if name := mux.Snapshot().Name; name != "TestNamePrefix" {
+		t.Errorf("Snapshot should return the bare name, got %q", name)
+	}
+}
+
+func TestCompactOps(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestCompactOps", DebugAll: true, CompactOps: true}
+	ops := func() {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RUnlock()
+	}
+
+	ops()
+	want := []string{"[L] [loggedMUTEX] Lock '", "[U] [loggedMUTEX] Unlock '", "[r] [loggedMUTEX] RLock '", "[u] [loggedMUTEX] RUnlock '"}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log %d lines, got %q", len(want), got)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("line %d should start with %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	buf.Reset()
+	mux.CompactOpsOnly = true
+	ops()
+	got = lines(buf)
+	for i, code := range []string{"[L]", "[U]", "[r]", "[u]"} {
+		if !strings.HasPrefix(got[i], code+" [loggedMUTEX] 'TestCompactOps'") {
+			t.Errorf("line %d should only have the op code %s, got %q", i, code, got[i])
+		}
+	}
+}
+
+func TestRelativeTimestamps(t *testing.T) {
+	clock := useFakeClock(t)
+	buf := captureOutput(t)
+	RelativeTimestamps = true
+	origStart := processStart
+	processStart = clock.now()
+	defer func() {
+		RelativeTimestamps = false
+		processStart = origStart
+	}()
+	mux := &LoggedSyncRWMutex{Name: "TestRelativeTimestamps", DebugAll: true}
+
+	clock.advance(1234 * time.Millisecond)
+	mux.Lock()
+	clock.advance(time.Second)
+	mux.Unlock()
+
+	got := lines(buf)
+	if len(got) != 2 || !strings.HasPrefix(got[0], "+1234ms [loggedMUTEX] Lock ") || !strings.HasPrefix(got[1], "+2234ms [loggedMUTEX] Unlock ") {
+		t.Errorf("lines should start with the elapsed time, got %q", got)
+	}
+	re := regexp.MustCompile(`^\+\d+ms `)
+	for _, line := range got {
+		if !re.MatchString(line) {
+			t.Errorf("line should start with a +Nms token: %q", line)
+		}
+	}
+}
+
+func TestLogGaps(t *testing.T) {
+	clock := useFakeClock(t)
+	buf := captureOutput(t)
+	mux := &LoggedSyncRWMutex{Name: "TestLogGaps", DebugLock: DebugOn, LogGaps: true}
+
+	mux.Lock()
+	mux.Unlock()
+	clock.advance(3 * time.Millisecond)
+	mux.Lock()
+	mux.Unlock()
+	clock.advance(250 * time.Millisecond)
+	mux.Lock()
+	mux.Unlock()
+
+	got := lines(buf)
+	if len(got) != 3 {
+		t.Fatalf("should log 3 lines, got %q", got)
+	}
+	if strings.Contains(got[0], "gap=") {
+		t.Errorf("first line should have no gap: %q", got[0])
+	}
+	if !strings.HasSuffix(got[1], " gap=3ms") {
+		t.Errorf("second line should have gap=3ms: %q", got[1])
+	}
+	if !strings.HasSuffix(got[2], " gap=250ms") {
+		t.Errorf("third line should have gap=250ms: %q", got[2])
+	}
+}
+
+func TestFloatPrecision(t *testing.T) {
+	defer func(p int) { FloatPrecision = p }(FloatPrecision)
+	useRegistry(t)
+	mux := Register(&LoggedSyncRWMutex{Name: "TestFloatPrecision"})
+	prev := map[*LoggedSyncRWMutex]Stats{mux: {}}
+	cur := map[*LoggedSyncRWMutex]Stats{mux: {Name: "TestFloatPrecision", TotalLocked: 10, TotalRLocked: 1}}
+
+	FloatPrecision = 2
+	var buf bytes.Buffer
+	writeRates(&buf, prev, cur, 3*time.Second)
+	if want := "lock/s=3.33 rlock/s=0.33\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("rates should render with two decimals %q, got %q", want, buf.String())
+	}
+
+	FloatPrecision = -1
+	if got := formatFloat(2.5); got != "2.5" {
+		t.Errorf("FloatPrecision -1 should use the shortest representation, got %q", got)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{ writes int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("broken pipe")
+}
+
+func TestMaxWriteErrors(t *testing.T) {
+	warns := captureWarnings(t)
+	w := &failingWriter{}
+	mux := &LoggedSyncRWMutex{Name: "TestMaxWriteErrors", DebugAll: true, Writer: w}
+
+	for i := 0; i < 20; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	if w.writes != int(MaxWriteErrors) {
+		t.Errorf("should stop writing after %d failed writes, got %d", MaxWriteErrors, w.writes)
+	}
+	if n := mux.Snapshot().WriteErrors; n != MaxWriteErrors {
+		t.Errorf("WriteErrors should be %d, got %d", MaxWriteErrors, n)
+	}
+	got := lines(warns)
+	if len(got) != 1 || !strings.Contains(got[0], "write-error: 10 failed writes, logging disabled: broken pipe") {
+		t.Errorf("should warn once about the disabled logging, got %q", got)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 20 {
+		t.Errorf("counting should continue, got %+v", st)
+	}
+}
+
+func TestFieldSeparator(t *testing.T) {
+	useRegistry(t)
+	buf := captureOutput(t)
+	FieldSeparator = "\t"
+	defer func() { FieldSeparator = " " }()
+	mux := Register(&LoggedSyncRWMutex{Name: "TestFieldSeparator", DebugAll: true})
+	ctx := context.WithValue(context.Background(), CorrelationKey, "req-1")
+
+	mux.LockLabeled("flush")
+	mux.Unlock()
+	if err := mux.RLockCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mux.RUnlock()
+	mux.CompactOps = true
+	mux.Lock()
+	mux.Unlock()
+	DumpAll(buf)
+
+	want := []string{
+		"[loggedMUTEX]\tLock\t'TestFieldSeparator'\t(flush)\tlocked=1/1",
+		"[loggedMUTEX]\tUnlock\t'TestFieldSeparator'\t(flush)\tlocked=0/1",
+		"[loggedMUTEX]\tRLock\t'TestFieldSeparator'\trLocked=1/1\tid=req-1",
+		"[loggedMUTEX]\tRUnlock\t'TestFieldSeparator'\trLockedCount=0/1",
+		"[L]\t[loggedMUTEX]\tLock\t'TestFieldSeparator'\tlocked=1/2",
+		"[U]\t[loggedMUTEX]\tUnlock\t'TestFieldSeparator'\tlocked=0/2",
+		"[loggedMUTEX]\tStatus\t'TestFieldSeparator'\tlocked=0\trLocked=0\ttotalLocked=2\ttotalUnlocked=2\ttotalrLocked=1\ttotalrUnlocked=1",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log %d lines, got %q", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+}
+
+func TestVersionTag(t *testing.T) {
+	buf := captureOutput(t)
+	VersionTag = "v1.2.3"
+	defer func() { VersionTag = "" }()
+	mux := &LoggedSyncRWMutex{Name: "TestVersionTag", DebugAll: true}
+
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.JSONLines = true
+	mux.Lock()
+	mux.Unlock()
+
+	got := lines(buf)
+	want := []string{
+		"[loggedMUTEX] Lock 'TestVersionTag' locked=1/1 ver=v1.2.3",
+		"[loggedMUTEX] Unlock 'TestVersionTag' locked=0/1 ver=v1.2.3",
+		"[loggedMUTEX] RLock 'TestVersionTag' rLocked=1/1 ver=v1.2.3",
+		"[loggedMUTEX] RUnlock 'TestVersionTag' rLockedCount=0/1 ver=v1.2.3",
+	}
+	if len(got) != 6 {
+		t.Fatalf("should log 6 lines, got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+	for _, line := range got[4:] {
+		if !strings.Contains(line, `"ver":"v1.2.3"`) {
+			t.Errorf("JSON line should carry the version, got %q", line)
+		}
+	}
+
+	// no tag, no field
+	VersionTag = ""
+	buf.Reset()
+	mux.JSONLines = false
+	mux.Lock()
+	mux.Unlock()
+	if strings.Contains(buf.String(), "ver") {
+		t.Errorf("lines should not carry a version without VersionTag, got %q", buf.String())
+	}
+}
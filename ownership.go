@@ -0,0 +1,267 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// goidFunc is the goroutine id func set by SetGoroutineIDFunc.
+var goidFunc atomic.Pointer[func() int64]
+
+// SetGoroutineIDFunc replaces the func used to identify goroutines for all ownership
+// and reentrancy features, e.g. with a faster one from a goroutine-local storage library.
+// The ids must be unique among live goroutines and not 0. Pass nil to restore the default,
+// which parses runtime.Stack.
+func SetGoroutineIDFunc(f func() int64) {
+	if f == nil {
+		goidFunc.Store(nil)
+		return
+	}
+	goidFunc.Store(&f)
+}
+
+// goid returns the id of the calling goroutine.
+func goid() int64 {
+	if f := goidFunc.Load(); f != nil {
+		return (*f)()
+	}
+	return stackGoid()
+}
+
+// stackGoid returns the id of the calling goroutine.
+// It is parsed from the header of runtime.Stack ("goroutine 18 [running]:")
+// which is slow and only meant for debugging.
+func stackGoid() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	b := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+	return id
+}
+
+// ownerAcquired records the goroutine gid as holder of the lock.
+// Must be called with m.mu held after the embedded RWMutex has been acquired.
+func (m *LoggedSyncRWMutex) ownerAcquired(gid int64, write bool) {
+	f := m.feat()
+	if write {
+		f.writeOwner, f.writeSince = gid, now()
+		return
+	}
+	if f.readOwners == nil {
+		f.readOwners = make(map[int64]int)
+		f.readSince = make(map[int64]time.Time)
+	}
+	if f.readOwners[gid] == 0 {
+		if full(len(f.readOwners)) {
+			m.evictReadOwner()
+		}
+		f.readSince[gid] = now()
+	}
+	f.readOwners[gid]++
+}
+
+// ownerReleased removes the goroutine gid as holder of the lock.
+// Must be called with m.mu held before the embedded RWMutex is released,
+// or the next holder may be overwritten.
+func (m *LoggedSyncRWMutex) ownerReleased(gid int64, write bool) {
+	f := m.feat()
+	if write {
+		f.writeOwner, f.writeSince = 0, time.Time{}
+		return
+	}
+	if f.readOwners[gid] <= 1 {
+		delete(f.readOwners, gid)
+		delete(f.readSince, gid)
+		return
+	}
+	f.readOwners[gid]--
+}
+
+// tracking reports whether ownership tracking is active for this mutex.
+func (m *LoggedSyncRWMutex) tracking() bool {
+	if m.disabled() {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.TrackOwnership
+}
+
+// tracksOwners reports whether any enabled feature needs the owning goroutines recorded.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) tracksOwners() bool {
+	return m.TrackOwnership || m.DetectRLockThenLock || m.DetectLockThenRLock || m.StrictOwnership
+}
+
+// checkStrictOwner panics with a *MisuseError if the goroutine gid releases a lock it does not hold.
+// Must be called with m.mu held before anything of the release is recorded.
+func (m *LoggedSyncRWMutex) checkStrictOwner(gid int64, write bool) {
+	f := m.view()
+	if write {
+		if f.writeOwner != 0 && f.writeOwner != gid {
+			panic(m.misuseError(MisuseOwnership, opUnlock, "StrictOwnership: Unlock by goroutine %d, locked by goroutine %d", gid, f.writeOwner))
+		}
+		return
+	}
+	// evicted holders are unknown, see MaxTrackedEntries
+	if len(f.readOwners) > 0 && f.readOwners[gid] == 0 && f.trackingEvictions == 0 {
+		panic(m.misuseError(MisuseOwnership, opRUnlock, "StrictOwnership: RUnlock by goroutine %d without a read lock", gid))
+	}
+}
+
+// AssertHeld panics with a *MisuseError if the calling goroutine does not hold the write lock.
+// Requires TrackOwnership to be enabled before the lock was acquired.
+func (m *LoggedSyncRWMutex) AssertHeld() {
+	tracked := m.tracking()
+	gid := goid()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !tracked {
+		panic(m.misuseError(MisuseOwnership, 0, "AssertHeld requires TrackOwnership"))
+	}
+	if m.view().writeOwner != gid {
+		panic(m.misuseError(MisuseOwnership, 0, "AssertHeld failed: goroutine %d does not hold the lock", gid))
+	}
+}
+
+// AssertRHeld panics with a *MisuseError if the calling goroutine holds neither a read lock nor the write lock.
+// Requires TrackOwnership to be enabled before the lock was acquired. Once MaxTrackedEntries
+// has evicted a holder, an unknown goroutine passes while any read lock is held.
+func (m *LoggedSyncRWMutex) AssertRHeld() {
+	tracked := m.tracking()
+	gid := goid()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !tracked {
+		panic(m.misuseError(MisuseOwnership, 0, "AssertRHeld requires TrackOwnership"))
+	}
+	// evicted holders are unknown, see MaxTrackedEntries
+	f := m.view()
+	evicted := f.trackingEvictions > 0 && m.rLockedCount > 0
+	if f.writeOwner != gid && f.readOwners[gid] == 0 && !evicted {
+		panic(m.misuseError(MisuseOwnership, 0, "AssertRHeld failed: goroutine %d does not hold a read lock", gid))
+	}
+}
+
+// TransferOwnership declares that the calling goroutine hands its lock to goroutine
+// toGoroutine, which will release it: the write lock if the caller holds it, otherwise
+// one of its read locks. Afterwards StrictOwnership, AssertHeld and the self-deadlock
+// detection treat toGoroutine as the holder, and EnforceOrder and IndentByDepth
+// account the lock to it. Without ownership tracking the write lock is assumed
+// if it is held. A caller without a lock gets a warning and nothing changes.
+func (m *LoggedSyncRWMutex) TransferOwnership(toGoroutine int64) {
+	if m.disabled() {
+		return
+	}
+	gid := goid()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	write := m.lockedCount > 0
+	held := write || m.rLockedCount > 0
+	track := m.tracksOwners()
+	if track {
+		f := m.feat()
+		write = f.writeOwner == gid
+		held = write || f.readOwners[gid] > 0
+	}
+	if !held {
+		m.warnf(SeverityWarn, "transfer", "goroutine %d transfers a lock it does not hold to goroutine %d", gid, toGoroutine)
+		return
+	}
+	if track {
+		if write {
+			m.feat().writeOwner = toGoroutine
+		} else {
+			m.ownerReleased(gid, false)
+			m.ownerAcquired(toGoroutine, false)
+		}
+	}
+	if orderActive.Load() {
+		if name := orderReleased(gid, m); name != "" {
+			orderAcquired(toGoroutine, m, name)
+		}
+	}
+	if IndentByDepth {
+		depthReleased(gid)
+		depthAcquired(toGoroutine)
+	}
+}
+
+// HolderGoroutine returns the id of the goroutine holding the write lock
+// and whether the write lock is held. Requires TrackOwnership.
+func (m *LoggedSyncRWMutex) HolderGoroutine() (int64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	return f.writeOwner, f.writeOwner != 0
+}
+
+// GoroutineReadLeaks returns the goroutines with a residual read lock depth,
+// i.e. more RLock than RUnlock calls. Requires TrackOwnership.
+// Checked after the goroutines have finished their work, every entry is a leaked reader.
+func (m *LoggedSyncRWMutex) GoroutineReadLeaks() map[int64]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	leaks := make(map[int64]int, len(f.readOwners))
+	for gid, depth := range f.readOwners {
+		if depth > 0 {
+			leaks[gid] = depth
+		}
+	}
+	return leaks
+}
+
+// MaxUniqueGoroutines bounds the set of goroutine ids kept per mutex for CountGoroutines.
+// Each id costs about 40 bytes, the default caps the set at roughly 400KB per mutex.
+// Once the set is full further goroutines are not counted and UniqueGoroutines
+// returns a lower bound.
+var MaxUniqueGoroutines = 10000
+
+// goroutineSeen adds gid to the set of goroutines for UniqueGoroutines.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) goroutineSeen(gid int64) {
+	f := m.feat()
+	if f.goroutines == nil {
+		f.goroutines = make(map[int64]struct{})
+	}
+	if len(f.goroutines) < MaxUniqueGoroutines {
+		f.goroutines[gid] = struct{}{}
+	}
+}
+
+// UniqueGoroutines returns the number of distinct goroutines that acquired
+// the lock or a read lock while CountGoroutines was set, at most MaxUniqueGoroutines.
+func (m *LoggedSyncRWMutex) UniqueGoroutines() uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return uint64(len(m.view().goroutines))
+}
+
+// HeldInfo describes one holder of a lock, see HeldLocks.
+type HeldInfo struct {
+	Name      string
+	Mode      string // "write" or "read"
+	Goroutine int64
+	HeldSince time.Time // for nested read locks the outermost RLock
+}
+
+// held returns the current holders of the lock.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) held() []HeldInfo {
+	var list []HeldInfo
+	f := m.view()
+	if f.writeOwner != 0 {
+		list = append(list, HeldInfo{Name: m.Name, Mode: "write", Goroutine: f.writeOwner, HeldSince: f.writeSince})
+	}
+	for gid := range f.readOwners {
+		list = append(list, HeldInfo{Name: m.Name, Mode: "read", Goroutine: gid, HeldSince: f.readSince[gid]})
+	}
+	return list
+}
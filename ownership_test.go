@@ -0,0 +1,395 @@
+package loggedrwmutex
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAssertRHeld(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestAssertRHeld", TrackOwnership: true}
+
+	// not held at all
+	mustPanic(t, "AssertRHeld without lock", mux.AssertRHeld)
+
+	// held for reading
+	mux.RLock()
+	mux.AssertRHeld()
+
+	// held by another goroutine only
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mustPanic(t, "AssertRHeld from other goroutine", mux.AssertRHeld)
+	}()
+	<-done
+	mux.RUnlock()
+	mustPanic(t, "AssertRHeld after RUnlock", mux.AssertRHeld)
+
+	// nested read locks
+	mux.RLock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.AssertRHeld()
+	mux.RUnlock()
+	mustPanic(t, "AssertRHeld after nested RUnlock", mux.AssertRHeld)
+
+	// the write lock satisfies AssertRHeld
+	mux.Lock()
+	mux.AssertRHeld()
+	mux.AssertHeld()
+	mux.Unlock()
+	mustPanic(t, "AssertHeld after Unlock", mux.AssertHeld)
+
+	// AssertHeld is not satisfied by a read lock
+	mux.RLock()
+	mustPanic(t, "AssertHeld with read lock", mux.AssertHeld)
+	mux.RUnlock()
+
+	// without tracking nothing can be asserted
+	plain := &LoggedSyncRWMutex{Name: "TestAssertRHeldPlain"}
+	plain.RLock()
+	mustPanic(t, "AssertRHeld without TrackOwnership", plain.AssertRHeld)
+	plain.RUnlock()
+
+	// the panic value works with errors.As
+	err, _ := recovered(mux.AssertHeld).(error)
+	var me *MisuseError
+	if !errors.As(err, &me) || me.Kind != MisuseOwnership || me.Name != "TestAssertRHeld" {
+		t.Errorf("AssertHeld should panic with an ownership *MisuseError, got %v", err)
+	}
+}
+
+func TestHolderGoroutine(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestHolderGoroutine", TrackOwnership: true}
+	if _, held := mux.HolderGoroutine(); held {
+		t.Error("HolderGoroutine should report not held before Lock")
+	}
+
+	locked := make(chan int64)
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Lock()
+		locked <- goid()
+		<-release
+		mux.Unlock()
+	}()
+	want := <-locked
+	gid, held := mux.HolderGoroutine()
+	if !held || gid != want {
+		t.Errorf("HolderGoroutine should return %d,true got %d,%v", want, gid, held)
+	}
+	if gid == goid() {
+		t.Error("HolderGoroutine should not return the test goroutine")
+	}
+	close(release)
+	<-done
+	if gid, held := mux.HolderGoroutine(); held || gid != 0 {
+		t.Errorf("HolderGoroutine should return 0,false after Unlock, got %d,%v", gid, held)
+	}
+}
+
+func TestDetectRLockThenLock(t *testing.T) {
+	PanicOnMisuse = true
+	defer func() { PanicOnMisuse = false }()
+	mux := &LoggedSyncRWMutex{Name: "TestDetectRLockThenLock", DetectRLockThenLock: true}
+
+	mux.RLock()
+	mustPanic(t, "Lock while holding a read lock", mux.Lock)
+	mux.RUnlock()
+
+	// not detected once the read lock is released
+	mux.Lock()
+	mux.Unlock()
+
+	// a read lock of another goroutine only blocks
+	mux.RLock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Lock()
+		mux.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mux.RUnlock()
+	<-done
+
+	if st := mux.Snapshot(); st.Locked != 0 || st.TotalLocked != 2 {
+		t.Errorf("panicked Lock should not be counted, got %+v", st)
+	}
+}
+
+func TestDetectRLockThenLockWarning(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDetectRLockThenLockWarn", DetectRLockThenLock: true}
+
+	mux.RLock()
+	locked := make(chan struct{})
+	go func() {
+		// warns and blocks, as the read lock is held by the test goroutine not this one
+		mux.Lock()
+		close(locked)
+		mux.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mux.RUnlock()
+	<-locked
+	if buf.Len() != 0 {
+		t.Errorf("Lock without own read lock should not warn, got %q", buf.String())
+	}
+
+	// the warning is written before Lock would hang, checked here without hanging
+	mux.RLock()
+	mux.prepare(true)
+	mux.RUnlock()
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "self-deadlock") {
+		t.Errorf("should warn about the self-deadlock, got %q", got)
+	}
+}
+
+func TestGoroutineReadLeaks(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestGoroutineReadLeaks", TrackOwnership: true}
+
+	leaker := make(chan int64)
+	go func() {
+		mux.RLock()
+		mux.RLock()
+		mux.RUnlock()
+		leaker <- goid()
+	}()
+	gid := <-leaker
+
+	// balanced goroutine
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	<-done
+
+	leaks := mux.GoroutineReadLeaks()
+	if len(leaks) != 1 || leaks[gid] != 1 {
+		t.Errorf("GoroutineReadLeaks should return {%d: 1}, got %v", gid, leaks)
+	}
+	mux.RUnlock() // release the leaked read lock for cleanup
+}
+
+func TestUniqueGoroutines(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestUniqueGoroutines", CountGoroutines: true}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				mux.Lock()
+				mux.Unlock()
+				mux.RLock()
+				mux.RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+	mux.Lock()
+	mux.Unlock()
+	if n := mux.UniqueGoroutines(); n != 6 {
+		t.Errorf("UniqueGoroutines should be 6, got %d", n)
+	}
+
+	defer func(n int) { MaxUniqueGoroutines = n }(MaxUniqueGoroutines)
+	MaxUniqueGoroutines = 2
+	bounded := &LoggedSyncRWMutex{Name: "TestUniqueGoroutinesBounded", CountGoroutines: true}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bounded.Lock()
+			bounded.Unlock()
+		}()
+	}
+	wg.Wait()
+	if n := bounded.UniqueGoroutines(); n != 2 {
+		t.Errorf("UniqueGoroutines should stop at MaxUniqueGoroutines 2, got %d", n)
+	}
+}
+
+func TestStrictOwnership(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestStrictOwnership", StrictOwnership: true}
+	type result struct {
+		gid int64
+		p   any
+	}
+	fromOther := func(fn func()) result {
+		ch := make(chan result)
+		go func() { ch <- result{goid(), recovered(fn)} }()
+		return <-ch
+	}
+
+	mux.Lock()
+	r := fromOther(mux.Unlock)
+	want := fmt.Sprintf("loggedrwmutex: 'TestStrictOwnership' ownership: StrictOwnership: Unlock by goroutine %d, locked by goroutine %d", r.gid, goid())
+	if e, ok := r.p.(*MisuseError); !ok || e.Kind != MisuseOwnership || e.Op != "Unlock" || e.Error() != want {
+		t.Errorf("Unlock from another goroutine should panic with %q, got %v", want, r.p)
+	}
+	if st := mux.Snapshot(); st.Locked != 1 || st.TotalUnlocked != 0 {
+		t.Errorf("refused Unlock should not be counted, got %+v", st)
+	}
+	mux.Unlock() // the owner may unlock
+
+	mux.RLock()
+	r = fromOther(mux.RUnlock)
+	want = fmt.Sprintf("loggedrwmutex: 'TestStrictOwnership' ownership: StrictOwnership: RUnlock by goroutine %d without a read lock", r.gid)
+	if e, ok := r.p.(*MisuseError); !ok || e.Kind != MisuseOwnership || e.Op != "RUnlock" || e.Error() != want {
+		t.Errorf("RUnlock from another goroutine should panic with %q, got %v", want, r.p)
+	}
+	mux.RUnlock()
+}
+
+func TestDetectLockThenRLock(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDetectLockThenRLock", DetectLockThenRLock: true}
+
+	// a read lock of another goroutine only waits for the write lock
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mux.Unlock()
+	<-done
+	if buf.Len() != 0 {
+		t.Errorf("RLock without own write lock should not warn, got %q", buf.String())
+	}
+
+	// the warning is written before RLock would hang, checked here without hanging
+	mux.Lock()
+	mux.prepare(false)
+	mux.Unlock()
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "self-deadlock: goroutine") || !strings.Contains(got[0], "calls RLock while holding the write lock") {
+		t.Errorf("should warn about the self-deadlock, got %q", got)
+	}
+}
+
+func TestSetGoroutineIDFunc(t *testing.T) {
+	var next atomic.Int64
+	next.Store(100)
+	SetGoroutineIDFunc(func() int64 { return next.Load() })
+	defer SetGoroutineIDFunc(nil)
+
+	mux := &LoggedSyncRWMutex{Name: "TestSetGoroutineIDFunc", TrackOwnership: true}
+	mux.Lock()
+	if gid, held := mux.HolderGoroutine(); !held || gid != 100 {
+		t.Errorf("HolderGoroutine should use the id func and return 100, got %d,%v", gid, held)
+	}
+	mux.AssertHeld()
+	next.Store(101) // another "goroutine"
+	mustPanic(t, "AssertHeld with another id", mux.AssertHeld)
+	next.Store(100)
+	mux.Unlock()
+
+	SetGoroutineIDFunc(nil)
+	if id := goid(); id != stackGoid() {
+		t.Errorf("goid should use runtime.Stack again, got %d", id)
+	}
+}
+
+func TestTransferOwnership(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestTransferOwnership", StrictOwnership: true, TrackOwnership: true}
+	handoff := func(lock func(), unlock func()) any {
+		target := make(chan int64)
+		transferred := make(chan struct{})
+		result := make(chan any)
+		go func() {
+			target <- goid()
+			<-transferred
+			result <- recovered(func() {
+				mux.AssertRHeld()
+				unlock()
+			})
+		}()
+		lock()
+		mux.TransferOwnership(<-target)
+		close(transferred)
+		return <-result
+	}
+
+	if p := handoff(mux.Lock, mux.Unlock); p != nil {
+		t.Errorf("Unlock after a declared transfer should not panic, got %v", p)
+	}
+	if _, held := mux.HolderGoroutine(); held {
+		t.Error("the transferred write lock should be released")
+	}
+	if p := handoff(mux.RLock, mux.RUnlock); p != nil {
+		t.Errorf("RUnlock after a declared transfer should not panic, got %v", p)
+	}
+	if leaks := mux.GoroutineReadLeaks(); len(leaks) != 0 {
+		t.Errorf("the transferred read lock should be released, got %v", leaks)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("declared transfers should not warn, got %q", buf.String())
+	}
+
+	// the former holder is no owner anymore
+	mux.Lock()
+	mux.TransferOwnership(goid() + 1000)
+	mustPanic(t, "AssertHeld after the transfer", mux.AssertHeld)
+	mux.TransferOwnership(goid()) // warns, the caller holds nothing
+	mux.ext.writeOwner = goid()   // take it back to clean up
+	mux.Unlock()
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "transfer: goroutine") || !strings.Contains(got[0], "transfers a lock it does not hold") {
+		t.Errorf("a transfer without a lock should warn, got %q", got)
+	}
+}
+
+func TestAssertHeldSetName(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestAssertHeldSetName", TrackOwnership: true}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mux.SetName("TestAssertHeldSetName")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		recovered(mux.AssertHeld)
+	}
+	<-done
+	if got := mux.LogName(); got != "TestAssertHeldSetName" {
+		t.Errorf("LogName should return the name, got %q", got)
+	}
+}
+
+func TestAssertRHeldEvicted(t *testing.T) {
+	defer func(n int) { MaxTrackedEntries = n }(MaxTrackedEntries)
+	MaxTrackedEntries = 1
+	mux := &LoggedSyncRWMutex{Name: "TestAssertRHeldEvicted", TrackOwnership: true}
+
+	mux.RLock()
+	held, release, done := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock() // evicts the test goroutine
+		close(held)
+		<-release
+		mux.RUnlock()
+	}()
+	<-held
+	if p := recovered(mux.AssertRHeld); p != nil {
+		t.Errorf("AssertRHeld should pass for an evicted reader, got %v", p)
+	}
+	close(release)
+	<-done
+	mux.RUnlock()
+	mustPanic(t, "AssertRHeld without read locks", mux.AssertRHeld)
+}
@@ -0,0 +1,52 @@
+package loggedrwmutex
+
+import (
+	"time"
+)
+
+// paired reports whether PairedLogging applies, JSON lines always log every operation.
+func (m *LoggedSyncRWMutex) paired() bool {
+	return m.PairedLogging && !JSONLines && !m.JSONLines
+}
+
+// pairedAcquired remembers the wait of an acquisition for its paired line,
+// -1 if it was not timed.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) pairedAcquired(write bool, wait time.Duration) {
+	f := m.feat()
+	if write {
+		f.pairedWait = wait
+		return
+	}
+	f.pairedRWaits = append(f.pairedRWaits, wait)
+}
+
+// pairedReleased logs the paired line of a released lock with its hold
+// and wait durations unless JSON lines are written, fields that were not measured are omitted.
+// Read unlocks are matched with the oldest read lock like holdDone does.
+// Must be called with m.mu held, before the live counts are decremented.
+func (m *LoggedSyncRWMutex) pairedReleased(write bool, depth int, hold time.Duration, held bool) {
+	wait := time.Duration(-1)
+	op, flag, label := opLock, m.DebugUnlock, labelField(m.label)
+	f := m.feat()
+	if write {
+		wait, f.pairedWait = f.pairedWait, -1
+	} else {
+		op, flag, label = opRLock, m.DebugRUnlock, ""
+		if n := uint64(len(f.pairedRWaits)); n > 0 && n >= uint64(m.rLockedCount) {
+			wait = f.pairedRWaits[0]
+			f.pairedRWaits = f.pairedRWaits[1:]
+		}
+	}
+	if !m.paired() || !m.debug(flag) {
+		return
+	}
+	var heldField, waitField string
+	if held {
+		heldField = "held=" + hold.String()
+	}
+	if wait >= 0 {
+		waitField = "waited=" + wait.String()
+	}
+	m.logOp(op, depth, m.nameField(), label, heldField, waitField)
+}
@@ -0,0 +1,49 @@
+package loggedrwmutex
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPairedLogging(t *testing.T) {
+	buf := captureOutput(t)
+	clock := useFakeClock(t)
+	mux := &LoggedSyncRWMutex{Name: "TestPairedLogging", DebugAll: true, PairedLogging: true, MeasureHold: true, MeasureContention: true}
+
+	mux.LockLabeled("flush")
+	clock.advance(3 * time.Millisecond)
+	mux.Unlock()
+	mux.RLock()
+	clock.advance(time.Millisecond)
+	mux.RLock()
+	clock.advance(time.Millisecond)
+	mux.RUnlock()
+	mux.RUnlock()
+
+	want := []string{
+		"[loggedMUTEX] Lock 'TestPairedLogging' (flush) held=3ms waited=0s",
+		"[loggedMUTEX] RLock 'TestPairedLogging' held=2ms waited=0s",
+		"[loggedMUTEX] RLock 'TestPairedLogging' held=1ms waited=0s",
+	}
+	got := lines(buf)
+	if len(got) != len(want) {
+		t.Fatalf("should log one line per critical section, got %q", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d should be %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	// unmeasured durations are omitted
+	buf.Reset()
+	mux.MeasureHold, mux.MeasureContention = false, false
+	mux.Lock()
+	mux.Unlock()
+	if got := lines(buf); len(got) != 1 || got[0] != "[loggedMUTEX] Lock 'TestPairedLogging'" {
+		t.Errorf("should log the paired line without durations, got %q", got)
+	}
+	if len(mux.ext.pairedRWaits) != 0 {
+		t.Errorf("read waits should be released, got %v", mux.ext.pairedRWaits)
+	}
+}
@@ -0,0 +1,21 @@
+package loggedrwmutex
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// blockedOnLabel is the pprof label key set by ProfileLabels.
+const blockedOnLabel = "blocked_on"
+
+// acquireLabeled runs acquire with the pprof label blocked_on=<name> added to the labels
+// of ctx, so CPU and goroutine profiles show which mutex a goroutine waits for.
+// name is the logName captured by prepare under m.mu.
+// Afterwards the goroutine has the labels of ctx again: Lock and RLock pass
+// context.Background, which also drops labels set by the caller with
+// pprof.SetGoroutineLabels, LockCtx and RLockCtx restore those of their ctx.
+func acquireLabeled(ctx context.Context, name string, acquire func()) {
+	pprof.Do(ctx, pprof.Labels(blockedOnLabel, name), func(context.Context) {
+		acquire()
+	})
+}
@@ -0,0 +1,128 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+// goroutineProfile returns the goroutine profile with labels.
+func goroutineProfile(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatalf("goroutine profile: %v", err)
+	}
+	return buf.String()
+}
+
+func TestProfileLabels(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestProfileLabels", ProfileLabels: true}
+	label := `"blocked_on":"TestProfileLabels"`
+
+	for _, write := range []bool{true, false} {
+		mux.Lock()
+		acquired := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			lock, unlock := mux.RLock, mux.RUnlock
+			if write {
+				lock, unlock = mux.Lock, mux.Unlock
+			}
+			lock()
+			close(acquired)
+			<-release
+			unlock()
+		}()
+		for mux.Snapshot().Waiters != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		if p := goroutineProfile(t); !strings.Contains(p, label) {
+			t.Errorf("write=%v: profile should label the waiting goroutine with %s, got:\n%s", write, label, p)
+		}
+		mux.Unlock()
+		<-acquired
+		if p := goroutineProfile(t); strings.Contains(p, label) {
+			t.Errorf("write=%v: label should be removed after the acquisition, got:\n%s", write, p)
+		}
+		close(release)
+		<-done
+	}
+}
+
+func TestProfileLabelsCtx(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestProfileLabelsCtx", ProfileLabels: true}
+	mux.Lock()
+	ctx := pprof.WithLabels(context.Background(), pprof.Labels("request", "r1"))
+	acquired := make(chan error)
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pprof.SetGoroutineLabels(ctx)
+		err := mux.LockCtx(ctx)
+		acquired <- err
+		<-release
+		if err == nil {
+			mux.Unlock()
+		}
+	}()
+	for mux.Snapshot().Waiters != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if p := goroutineProfile(t); !strings.Contains(p, `"blocked_on":"TestProfileLabelsCtx"`) || !strings.Contains(p, `"request":"r1"`) {
+		t.Errorf("profile should show both labels of the waiting goroutine, got:\n%s", p)
+	}
+	mux.Unlock()
+	if err := <-acquired; err != nil {
+		t.Fatalf("LockCtx failed: %v", err)
+	}
+	if p := goroutineProfile(t); strings.Contains(p, "blocked_on") || !strings.Contains(p, `"request":"r1"`) {
+		t.Errorf("the labels of ctx should be restored after the acquisition, got:\n%s", p)
+	}
+	close(release)
+	<-done
+}
+
+func TestProfileLabelsSetName(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestProfileLabelsSetName", ProfileLabels: true}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mux.SetName("TestProfileLabelsSetName")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	<-done
+}
+
+func TestProfileLabelsReset(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestProfileLabelsReset", ProfileLabels: true}
+	done := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		defer close(done)
+		pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels("request", "r2")))
+		mux.Lock()
+		mux.Unlock()
+		<-release
+	}()
+	for i := 0; i < 100 && mux.Snapshot().TotalUnlocked == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	// Lock labels through context.Background and drops the labels of the goroutine
+	if p := goroutineProfile(t); strings.Contains(p, `"request":"r2"`) {
+		t.Errorf("Lock should reset the goroutine labels, got:\n%s", p)
+	}
+	close(release)
+	<-done
+}
@@ -0,0 +1,29 @@
+//go:build race
+
+package loggedrwmutex
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// raceEnabled reports whether the package is built with the race detector.
+const raceEnabled = true
+
+// raceAcquired annotates an acquisition for the race detector with RaceAnnotations.
+// Writers synchronize with the previous writer and all readers, readers with the previous writer.
+func (m *LoggedSyncRWMutex) raceAcquired(write bool) {
+	runtime.RaceAcquire(unsafe.Pointer(m))
+	if write {
+		runtime.RaceAcquire(unsafe.Pointer(&m.rLockedCount))
+	}
+}
+
+// raceReleasing annotates a release for the race detector with RaceAnnotations.
+func (m *LoggedSyncRWMutex) raceReleasing(write bool) {
+	if write {
+		runtime.RaceRelease(unsafe.Pointer(m))
+		return
+	}
+	runtime.RaceReleaseMerge(unsafe.Pointer(&m.rLockedCount))
+}
@@ -0,0 +1,47 @@
+package loggedrwmutex
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestRaceAnnotations is meant for go test -race: the race detector fails it
+// if the mutex or its instrumentation does not order the accesses.
+func TestRaceAnnotations(t *testing.T) {
+	mux := &LoggedSyncRWMutex{
+		Name:            "TestRaceAnnotations",
+		RaceAnnotations: true,
+		TrackOwnership:  true,
+		MeasureHold:     true,
+		HistorySize:     16,
+		CountGoroutines: true,
+	}
+	shared := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mux.Lock()
+				shared++
+				mux.Unlock()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				mux.RLock()
+				_ = shared
+				mux.RUnlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if shared != 400 {
+		t.Errorf("shared should be 400, got %d", shared)
+	}
+	if !raceEnabled {
+		t.Log("built without -race, the annotations are no-ops")
+	}
+}
@@ -0,0 +1,156 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	registryMu   sync.Mutex
+	registryOnce sync.Once                     // creates registry exactly once, even on concurrent first registrations
+	registry     map[string]*LoggedSyncRWMutex // Name -> mutex
+)
+
+// initRegistry creates the registry map.
+func initRegistry() {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = make(map[string]*LoggedSyncRWMutex)
+}
+
+// Register adds m to the package registry under its Name and returns m.
+// A mutex registered earlier under the same Name is replaced.
+// DebugAll is enabled if its Name was listed by ConfigureFromEnv.
+//
+//	var mux = loggedrwmutex.Register(&loggedrwmutex.LoggedSyncRWMutex{Name: "ResourceMutex"})
+func Register(m *LoggedSyncRWMutex) *LoggedSyncRWMutex {
+	registryOnce.Do(initRegistry)
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[m.Name] = m
+	if debugWanted(m.Name) {
+		m.configureDebug()
+	}
+	return m
+}
+
+// Unregister removes m from the package registry.
+func Unregister(m *LoggedSyncRWMutex) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if registry[m.Name] == m {
+		delete(registry, m.Name)
+	}
+}
+
+// RegisteredNames returns the sorted names of all registered mutexes.
+func RegisteredNames() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registered returns all registered mutexes sorted by name.
+func registered() []*LoggedSyncRWMutex {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := make([]*LoggedSyncRWMutex, len(names))
+	for i, name := range names {
+		list[i] = registry[name]
+	}
+	return list
+}
+
+// DumpAll writes the status of every registered mutex to w.
+func DumpAll(w io.Writer) {
+	DumpFiltered(w, nil)
+}
+
+// DumpFiltered writes the status of every registered mutex whose Snapshot satisfies pred to w.
+// A nil pred matches all mutexes.
+//
+//	loggedrwmutex.DumpFiltered(os.Stderr, func(s loggedrwmutex.Stats) bool { return s.Locked > 0 || s.RLocked > 0 })
+func DumpFiltered(w io.Writer, pred func(Stats) bool) {
+	for _, m := range registered() {
+		st := m.Snapshot()
+		if pred != nil && !pred(st) {
+			continue
+		}
+		fmt.Fprintln(w, strings.Join([]string{"[loggedMUTEX]", "Status", "'" + NamePrefix + st.Name + "'",
+			fmt.Sprintf("locked=%d", st.Locked), fmt.Sprintf("rLocked=%d", st.RLocked),
+			fmt.Sprintf("totalLocked=%d", st.TotalLocked), fmt.Sprintf("totalUnlocked=%d", st.TotalUnlocked),
+			fmt.Sprintf("totalrLocked=%d", st.TotalRLocked), fmt.Sprintf("totalrUnlocked=%d", st.TotalRUnlocked)}, FieldSeparator))
+	}
+}
+
+// DumpTable writes the counters of every registered mutex to w as aligned table sorted by name.
+func DumpTable(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Name\tLocked\tRLocked\tTotalLocked\tTotalUnlocked")
+	for _, m := range registered() {
+		st := m.Snapshot()
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", NamePrefix+st.Name, st.Locked, st.RLocked, st.TotalLocked, st.TotalUnlocked)
+	}
+	tw.Flush()
+}
+
+// GroupWaitStats returns the summed wait time and the longest single wait
+// of all registered mutexes with the given Group. Requires MeasureContention.
+func GroupWaitStats(group string) (total, max time.Duration) {
+	for _, m := range registered() {
+		st := m.Snapshot()
+		if st.Group != group {
+			continue
+		}
+		total += st.WaitTotal
+		if st.WaitMax > max {
+			max = st.WaitMax
+		}
+	}
+	return total, max
+}
+
+// HeldLocks returns every holder of a registered mutex, longest held first.
+// Only mutexes that record their owners know their holders, i.e. with TrackOwnership,
+// StrictOwnership, DetectRLockThenLock or DetectLockThenRLock set before the lock
+// was taken. Readers evicted by MaxTrackedEntries are missing.
+func HeldLocks() []HeldInfo {
+	var list []HeldInfo
+	for _, m := range registered() {
+		m.mu.Lock()
+		list = append(list, m.held()...)
+		m.mu.Unlock()
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].HeldSince.Before(list[j].HeldSince) })
+	return list
+}
+
+// SetName renames the mutex while it may be in use by other goroutines
+// and moves it to the new name in the registry if it is registered.
+func (m *LoggedSyncRWMutex) SetName(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if registry[m.Name] == m {
+		delete(registry, m.Name)
+		registry[name] = m
+	}
+	m.Name = name
+	m.gcSync()
+}
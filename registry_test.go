@@ -0,0 +1,229 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useRegistry starts the test with an empty registry and restores it when the test ends.
+func useRegistry(t *testing.T) {
+	registryOnce.Do(initRegistry)
+	registryMu.Lock()
+	orig := registry
+	registry = make(map[string]*LoggedSyncRWMutex)
+	registryMu.Unlock()
+	t.Cleanup(func() {
+		registryMu.Lock()
+		registry = orig
+		registryMu.Unlock()
+	})
+}
+
+func TestRegisteredNames(t *testing.T) {
+	useRegistry(t)
+	if names := RegisteredNames(); len(names) != 0 {
+		t.Errorf("registry should be empty, got %q", names)
+	}
+
+	Register(&LoggedSyncRWMutex{Name: "cache"})
+	db := Register(&LoggedSyncRWMutex{Name: "db"})
+	Register(&LoggedSyncRWMutex{Name: "api"})
+
+	want := []string{"api", "cache", "db"}
+	if names := RegisteredNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("RegisteredNames should return %q, got %q", want, names)
+	}
+
+	Unregister(db)
+	want = []string{"api", "cache"}
+	if names := RegisteredNames(); !reflect.DeepEqual(names, want) {
+		t.Errorf("RegisteredNames after Unregister should return %q, got %q", want, names)
+	}
+}
+
+func TestDumpFiltered(t *testing.T) {
+	useRegistry(t)
+	a := Register(&LoggedSyncRWMutex{Name: "dump-a"})
+	b := Register(&LoggedSyncRWMutex{Name: "dump-b"})
+	Register(&LoggedSyncRWMutex{Name: "dump-c"})
+	a.Lock()
+	a.Unlock()
+	b.RLock()
+	defer b.RUnlock()
+
+	var buf bytes.Buffer
+	DumpAll(&buf)
+	if got := lines(&buf); len(got) != 3 {
+		t.Errorf("DumpAll should dump 3 mutexes, got %q", got)
+	}
+
+	buf.Reset()
+	DumpFiltered(&buf, func(s Stats) bool { return s.Locked > 0 || s.RLocked > 0 })
+	got := lines(&buf)
+	if len(got) != 1 || got[0] != "[loggedMUTEX] Status 'dump-b' locked=0 rLocked=1 totalLocked=0 totalUnlocked=0 totalrLocked=1 totalrUnlocked=0" {
+		t.Errorf("held only filter should dump only dump-b, got %q", got)
+	}
+}
+
+func TestSetName(t *testing.T) {
+	useRegistry(t)
+	buf := captureOutput(t)
+	mux := Register(&LoggedSyncRWMutex{Name: "old-name", DebugLock: DebugOn})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			mux.Lock()
+			mux.Unlock()
+		}
+	}()
+	mux.SetName("new-name")
+	<-done
+
+	buf.Reset()
+	mux.Lock()
+	mux.Unlock()
+	if !strings.Contains(buf.String(), "'new-name'") {
+		t.Errorf("log line should show the new name, got %q", buf.String())
+	}
+	if names := RegisteredNames(); !reflect.DeepEqual(names, []string{"new-name"}) {
+		t.Errorf("registry should use the new name, got %q", names)
+	}
+
+	// unregistered mutexes are not added to the registry
+	other := &LoggedSyncRWMutex{Name: "unregistered"}
+	other.SetName("renamed")
+	if names := RegisteredNames(); len(names) != 1 {
+		t.Errorf("SetName should not register, got %q", names)
+	}
+}
+
+func TestGroupWaitStats(t *testing.T) {
+	useRegistry(t)
+	db := Register(&LoggedSyncRWMutex{Name: "db", Group: "storage", MeasureContention: true})
+	cache := Register(&LoggedSyncRWMutex{Name: "cache", Group: "storage", MeasureContention: true})
+	api := Register(&LoggedSyncRWMutex{Name: "api", Group: "http", MeasureContention: true})
+
+	wait := func(m *LoggedSyncRWMutex, d time.Duration) {
+		m.Lock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.Lock()
+			m.Unlock()
+		}()
+		time.Sleep(d)
+		m.Unlock()
+		<-done
+	}
+	wait(db, 10*time.Millisecond)
+	wait(cache, 40*time.Millisecond)
+	wait(api, 80*time.Millisecond)
+
+	total, max := GroupWaitStats("storage")
+	dbMax, cacheMax := db.Snapshot().WaitMax, cache.Snapshot().WaitMax
+	if max != cacheMax || max < 40*time.Millisecond {
+		t.Errorf("group max should be the wait of cache %v, got %v", cacheMax, max)
+	}
+	if max >= api.Snapshot().WaitMax {
+		t.Errorf("group max %v should not include the other group", max)
+	}
+	if total < dbMax+cacheMax {
+		t.Errorf("group total %v should include both waits %v and %v", total, dbMax, cacheMax)
+	}
+	if total, max := GroupWaitStats("unknown"); total != 0 || max != 0 {
+		t.Errorf("unknown group should have no waits, got %v %v", total, max)
+	}
+}
+
+func TestHeldLocks(t *testing.T) {
+	useRegistry(t)
+	clock := useFakeClock(t)
+	db := Register(&LoggedSyncRWMutex{Name: "db", TrackOwnership: true})
+	cache := Register(&LoggedSyncRWMutex{Name: "cache", TrackOwnership: true})
+	Register(&LoggedSyncRWMutex{Name: "idle", TrackOwnership: true})
+
+	db.Lock()
+	clock.advance(time.Second)
+	cache.RLock()
+	cache.RLock()
+	reader := make(chan int64)
+	release := make(chan struct{})
+	go func() {
+		clock.advance(time.Second)
+		cache.RLock()
+		reader <- goid()
+		<-release
+		cache.RUnlock()
+		close(reader)
+	}()
+	other := <-reader
+
+	held := HeldLocks()
+	want := []HeldInfo{
+		{Name: "db", Mode: "write", Goroutine: goid(), HeldSince: time.Unix(1000, 0)},
+		{Name: "cache", Mode: "read", Goroutine: goid(), HeldSince: time.Unix(1001, 0)},
+		{Name: "cache", Mode: "read", Goroutine: other, HeldSince: time.Unix(1002, 0)},
+	}
+	if !reflect.DeepEqual(held, want) {
+		t.Errorf("HeldLocks should return %+v, got %+v", want, held)
+	}
+
+	close(release)
+	<-reader
+	cache.RUnlock()
+	cache.RUnlock()
+	db.Unlock()
+	if held := HeldLocks(); len(held) != 0 {
+		t.Errorf("HeldLocks should be empty after releasing, got %+v", held)
+	}
+}
+
+func TestRegisterConcurrent(t *testing.T) {
+	useRegistry(t)
+	const goroutines, each = 8, 50
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				Register(&LoggedSyncRWMutex{Name: fmt.Sprintf("TestRegisterConcurrent-%d-%d", g, i)})
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(RegisteredNames()); n != goroutines*each {
+		t.Errorf("registry should contain %d mutexes, got %d", goroutines*each, n)
+	}
+}
+
+func TestDumpTable(t *testing.T) {
+	useRegistry(t)
+	b := Register(&LoggedSyncRWMutex{Name: "TestDumpTableB"})
+	a := Register(&LoggedSyncRWMutex{Name: "TestDumpTableLongerNameA"})
+	Register(&LoggedSyncRWMutex{Name: "TestDumpTableC"})
+	for i := 0; i < 12; i++ {
+		b.Lock()
+		b.Unlock()
+	}
+	a.RLock()
+	defer a.RUnlock()
+
+	var buf bytes.Buffer
+	DumpTable(&buf)
+	want := "" +
+		"Name                      Locked  RLocked  TotalLocked  TotalUnlocked\n" +
+		"TestDumpTableB            0       0        12           12\n" +
+		"TestDumpTableC            0       0        0            0\n" +
+		"TestDumpTableLongerNameA  0       1        0            0\n"
+	if buf.String() != want {
+		t.Errorf("DumpTable should write\n%s\ngot\n%s", want, buf.String())
+	}
+}
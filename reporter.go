@@ -0,0 +1,152 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StartRateReporter writes the Lock and RLock rates of every registered mutex to w
+// each interval, computed from the difference to the previous interval.
+// A SnapshotAndReset in between is detected by a total below the previous one,
+// the rate then only counts the operations since the reset. A reset the total
+// has already grown past again is not detected and the rate of that interval is too low.
+// The returned stop func ends the reporter and waits for it to exit.
+//
+//	stop := loggedrwmutex.StartRateReporter(os.Stderr, 10*time.Second)
+//	defer stop()
+func StartRateReporter(w io.Writer, interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		prev, last := snapshotRegistered(), now()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+			}
+			cur, t := snapshotRegistered(), now()
+			writeRates(w, prev, cur, t.Sub(last))
+			prev, last = cur, t
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		<-done
+	}
+}
+
+// snapshotRegistered returns the Snapshot of every registered mutex.
+func snapshotRegistered() map[*LoggedSyncRWMutex]Stats {
+	list := registered()
+	snaps := make(map[*LoggedSyncRWMutex]Stats, len(list))
+	for _, m := range list {
+		snaps[m] = m.Snapshot()
+	}
+	return snaps
+}
+
+// writeRates writes the rates between two snapshots taken elapsed apart in name order.
+func writeRates(w io.Writer, prev, cur map[*LoggedSyncRWMutex]Stats, elapsed time.Duration) {
+	secs := elapsed.Seconds()
+	if secs <= 0 {
+		return
+	}
+	for _, m := range registered() {
+		st, ok := cur[m]
+		if !ok {
+			continue // registered after the snapshot
+		}
+		old := prev[m]
+		fmt.Fprintln(w, strings.Join([]string{"[loggedMUTEX]", "Rate", "'" + NamePrefix + st.Name + "'",
+			"lock/s=" + formatFloat(float64(totalDelta(st.TotalLocked, old.TotalLocked))/secs),
+			"rlock/s=" + formatFloat(float64(totalDelta(st.TotalRLocked, old.TotalRLocked))/secs)}, FieldSeparator))
+	}
+}
+
+// totalDelta returns the growth of a total from old to cur. A total below old
+// has been zeroed by SnapshotAndReset in between, then cur is the growth since the reset.
+func totalDelta(cur, old uint64) uint64 {
+	if cur < old {
+		return cur
+	}
+	return cur - old
+}
+
+// StartSlowHoldAlert checks the p99 hold time of every registered mutex over each
+// interval and calls OnSlowHold for those exceeding threshold. It requires MeasureHold,
+// the percentile has the resolution of the latency buckets. Mutexes registered
+// while it runs are checked from their second interval on. Only one should run at a time.
+// The returned stop func ends the checks and waits for them to exit.
+//
+//	loggedrwmutex.OnSlowHold = func(name string, p99 time.Duration) { log.Printf("%s p99 hold %v", name, p99) }
+//	stop := loggedrwmutex.StartSlowHoldAlert(50*time.Millisecond, time.Minute)
+//	defer stop()
+func StartSlowHoldAlert(threshold, interval time.Duration) (stop func()) {
+	for _, m := range registered() {
+		m.nextInterval()
+	}
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				for _, m := range registered() {
+					m.stopInterval()
+				}
+				return
+			case <-ticker.C:
+			}
+			for _, m := range registered() {
+				h := m.nextInterval()
+				if h == nil {
+					continue
+				}
+				if p99 := h.percentile(0.99); p99 > threshold && OnSlowHold != nil {
+					OnSlowHold(m.LogName(), p99)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		<-done
+	}
+}
+
+// StartAutoStatus calls PrintStatus(true) every interval until the returned stop func is called.
+// stop waits for the reporting goroutine to exit.
+func (m *LoggedSyncRWMutex) StartAutoStatus(interval time.Duration) (stop func()) {
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				m.PrintStatus(true)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		<-done
+	}
+}
@@ -0,0 +1,160 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartRateReporter(t *testing.T) {
+	useRegistry(t)
+	mux := Register(&LoggedSyncRWMutex{Name: "TestStartRateReporter"})
+
+	var buf syncBuffer
+	stop := StartRateReporter(&buf, 5*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "[loggedMUTEX] Rate 'TestStartRateReporter' lock/s=") && time.Now().Before(deadline) {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RUnlock()
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop() // stopping twice is fine
+
+	out := buf.String()
+	if !strings.Contains(out, "[loggedMUTEX] Rate 'TestStartRateReporter' lock/s=") {
+		t.Fatalf("reporter should write rate lines, got %q", out)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if buf.String() != out {
+		t.Error("reporter should not write after stop")
+	}
+}
+
+func TestWriteRates(t *testing.T) {
+	useRegistry(t)
+	mux := Register(&LoggedSyncRWMutex{Name: "TestWriteRates"})
+	prev := map[*LoggedSyncRWMutex]Stats{mux: {Name: "TestWriteRates", TotalLocked: 10, TotalRLocked: 10}}
+	cur := map[*LoggedSyncRWMutex]Stats{mux: {Name: "TestWriteRates", TotalLocked: 30, TotalRLocked: 15}}
+
+	var buf bytes.Buffer
+	writeRates(&buf, prev, cur, 2*time.Second)
+	want := "[loggedMUTEX] Rate 'TestWriteRates' lock/s=10.00 rlock/s=2.50\n"
+	if buf.String() != want {
+		t.Errorf("writeRates should write %q, got %q", want, buf.String())
+	}
+}
+
+func TestStartAutoStatus(t *testing.T) {
+	var buf syncBuffer
+	mux := &LoggedSyncRWMutex{Name: "TestStartAutoStatus", Writer: &buf}
+	mux.Lock()
+	stop := mux.StartAutoStatus(5 * time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(buf.String(), "Status 'TestStartAutoStatus' locked=1") && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	mux.Unlock()
+
+	out := buf.String()
+	if !strings.Contains(out, "?? [loggedMUTEX] Status 'TestStartAutoStatus' locked=1") {
+		t.Fatalf("StartAutoStatus should print the status, got %q", out)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if buf.String() != out {
+		t.Error("StartAutoStatus should not print after stop")
+	}
+}
+
+func TestStartSlowHoldAlert(t *testing.T) {
+	useRegistry(t)
+	slow := Register(&LoggedSyncRWMutex{Name: "TestSlowHoldAlertSlow", MeasureHold: true})
+	fast := Register(&LoggedSyncRWMutex{Name: "TestSlowHoldAlertFast", MeasureHold: true})
+
+	alerts := make(chan string, 100)
+	var p99s []time.Duration
+	OnSlowHold = func(name string, p99 time.Duration) {
+		if name == "TestSlowHoldAlertSlow" {
+			p99s = append(p99s, p99) // only written by the alert goroutine, read after stop
+		}
+		alerts <- name
+	}
+	defer func() { OnSlowHold = nil }()
+
+	stop := StartSlowHoldAlert(5*time.Millisecond, 30*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for len(alerts) == 0 && time.Now().Before(deadline) {
+		slow.Lock()
+		time.Sleep(10 * time.Millisecond)
+		slow.Unlock()
+		fast.Lock()
+		fast.Unlock()
+	}
+	stop()
+	stop() // stopping twice is fine
+
+	close(alerts)
+	for name := range alerts {
+		if name != "TestSlowHoldAlertSlow" {
+			t.Errorf("only the slow mutex should alert, got %q", name)
+		}
+	}
+	if len(p99s) == 0 {
+		t.Fatal("OnSlowHold should fire for the slow mutex")
+	}
+	for _, p99 := range p99s {
+		if p99 < 10*time.Millisecond || p99 > time.Second {
+			t.Errorf("p99 should be about the 10ms hold, got %v", p99)
+		}
+	}
+	if slow.ext.intervalHist != nil {
+		t.Error("stop should end collecting interval hold times")
+	}
+}
+
+func TestRateReporterReset(t *testing.T) {
+	useRegistry(t)
+	mux := Register(&LoggedSyncRWMutex{Name: "TestRateReporterReset"})
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+
+	var buf syncBuffer
+	stop := StartRateReporter(&buf, 5*time.Millisecond)
+	for strings.Count(buf.String(), "\n") < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	mux.SnapshotAndReset()
+	n := strings.Count(buf.String(), "\n")
+	for strings.Count(buf.String(), "\n") < n+2 {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+
+	rate := regexp.MustCompile(`lock/s=([0-9.]+)`)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		m := rate.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("unexpected line %q", line)
+		}
+		if v, _ := strconv.ParseFloat(m[1], 64); v != 0 {
+			t.Errorf("no locks were taken while the reporter ran, got %q", line)
+		}
+	}
+
+	// a reset total counts from 0
+	prev := map[*LoggedSyncRWMutex]Stats{mux: {Name: "TestRateReporterReset", TotalLocked: 100, TotalRLocked: 10}}
+	cur := map[*LoggedSyncRWMutex]Stats{mux: {Name: "TestRateReporterReset", TotalLocked: 4, TotalRLocked: 12}}
+	var out bytes.Buffer
+	writeRates(&out, prev, cur, time.Second)
+	if want := "[loggedMUTEX] Rate 'TestRateReporterReset' lock/s=4.00 rlock/s=2.00\n"; out.String() != want {
+		t.Errorf("writeRates should treat a lower total as reset, want %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,45 @@
+package loggedrwmutex
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// stateVersion is the version of the ExportState encoding.
+const stateVersion = 1
+
+// stateSize is the size of an encoded state: version byte followed by
+// totalLocked, totalUnlocked, totalrLocked, totalrUnlocked as little endian uint64.
+const stateSize = 1 + 4*8
+
+// ExportState encodes the total counters of the mutex, so they can be
+// persisted and restored with ImportState after a restart.
+// Live state like active locks is not included.
+func (m *LoggedSyncRWMutex) ExportState() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := make([]byte, stateSize)
+	b[0] = stateVersion
+	binary.LittleEndian.PutUint64(b[1:], uint64(m.totalLocked))
+	binary.LittleEndian.PutUint64(b[9:], uint64(m.totalUnlocked))
+	binary.LittleEndian.PutUint64(b[17:], uint64(m.totalrLocked))
+	binary.LittleEndian.PutUint64(b[25:], uint64(m.totalrUnlocked))
+	return b
+}
+
+// ImportState replaces the total counters of the mutex with a state from ExportState.
+func (m *LoggedSyncRWMutex) ImportState(b []byte) error {
+	if len(b) == 0 || b[0] != stateVersion {
+		return fmt.Errorf("loggedrwmutex: unsupported state version")
+	}
+	if len(b) != stateSize {
+		return fmt.Errorf("loggedrwmutex: invalid state size %d", len(b))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totalLocked = counter(binary.LittleEndian.Uint64(b[1:]))
+	m.totalUnlocked = counter(binary.LittleEndian.Uint64(b[9:]))
+	m.totalrLocked = counter(binary.LittleEndian.Uint64(b[17:]))
+	m.totalrUnlocked = counter(binary.LittleEndian.Uint64(b[25:]))
+	return nil
+}
@@ -0,0 +1,39 @@
+package loggedrwmutex
+
+import (
+	"testing"
+)
+
+func TestExportImportState(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestExportState"}
+	for i := 0; i < 3; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	mux.RLock()
+	mux.RLock()
+	mux.RUnlock()
+	state := mux.ExportState()
+	mux.RUnlock()
+
+	fresh := &LoggedSyncRWMutex{Name: "TestExportState"}
+	if err := fresh.ImportState(state); err != nil {
+		t.Fatalf("ImportState failed: %v", err)
+	}
+	want := Stats{Name: "TestExportState", TotalLocked: 3, TotalUnlocked: 3, TotalRLocked: 2, TotalRUnlocked: 1}
+	if got := fresh.Snapshot(); got != want {
+		t.Errorf("imported state should be %+v, got %+v", want, got)
+	}
+
+	if err := fresh.ImportState(nil); err == nil {
+		t.Error("ImportState should fail on empty state")
+	}
+	bad := append([]byte{}, state...)
+	bad[0] = 99
+	if err := fresh.ImportState(bad); err == nil {
+		t.Error("ImportState should fail on unknown version")
+	}
+	if err := fresh.ImportState(state[:10]); err == nil {
+		t.Error("ImportState should fail on truncated state")
+	}
+}
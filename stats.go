@@ -0,0 +1,193 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"time"
+)
+
+// Stats is a point in time copy of the counters of a mutex.
+type Stats struct {
+	Name           string
+	Group          string
+	Locked         uint64 // active locks
+	RLocked        uint64 // active readers
+	Waiters        uint64 // goroutines waiting in Lock or RLock
+	ReaderWaiters  uint64 // goroutines waiting in RLock
+	WriterWaiters  uint64 // goroutines waiting in Lock
+	TotalLocked    uint64
+	TotalUnlocked  uint64
+	TotalRLocked   uint64
+	TotalRUnlocked uint64
+	TotalWeight    uint64 // sum of the weights of all write locks, 1 per Lock, see LockWeighted
+
+	WaitTotal       time.Duration // time spent waiting for the lock, requires MeasureContention
+	WaitMax         time.Duration // longest single wait
+	WorstWaiter     Waiter        // acquisition that waited WaitMax
+	Contended       uint64        // acquisitions that found the lock taken, requires ContentionTryFirst
+	Uncontended     uint64        // acquisitions that found the lock free, requires ContentionTryFirst
+	UnlockTimeTotal time.Duration // time spent releasing, requires MeasureHold
+	UnlockTimeMax   time.Duration
+	TrivialHolds    uint64 // holds shorter than TrivialHoldThreshold
+	WriteErrors     uint64 // failed writes of log lines
+
+	TrackingEvictions uint64 // entries evicted from tracking maps, see MaxTrackedEntries
+
+	FirstUse time.Time // first operation, requires RecordUse
+	LastUse  time.Time // last operation, requires RecordUse
+}
+
+// addWeight adds the weight of a write lock to the total.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) addWeight(w int) {
+	if w < 0 {
+		m.misuse(MisuseWeight, opLock, "negative weight %d is not counted", w)
+		return
+	}
+	m.totalWeight += counter(w)
+}
+
+// Snapshot returns a consistent copy of the counters.
+func (m *LoggedSyncRWMutex) Snapshot() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats()
+}
+
+// SnapshotAndReset returns the stats like Snapshot and zeroes the totals
+// (TotalLocked, TotalUnlocked, TotalRLocked, TotalRUnlocked and TotalWeight)
+// in the same critical section, so interval reports neither lose nor double count
+// operations. Active locks and timing values are kept.
+// A running StartRateReporter under-reports the interval of a reset, see there.
+func (m *LoggedSyncRWMutex) SnapshotAndReset() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.stats()
+	m.totalLocked, m.totalUnlocked, m.totalrLocked, m.totalrUnlocked, m.totalWeight = 0, 0, 0, 0, 0
+	m.resetLocked, m.resetRLocked = m.lockedCount, m.rLockedCount
+	return st
+}
+
+// stats returns the counters.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) stats() Stats {
+	f := m.view()
+	return Stats{
+		Name:           m.Name,
+		Group:          m.Group,
+		Locked:         uint64(m.lockedCount),
+		RLocked:        uint64(m.rLockedCount),
+		Waiters:        uint64(m.waiters()),
+		ReaderWaiters:  uint64(m.readerWaiters),
+		WriterWaiters:  uint64(m.writerWaiters),
+		TotalLocked:    uint64(m.totalLocked),
+		TotalUnlocked:  uint64(m.totalUnlocked),
+		TotalRLocked:   uint64(m.totalrLocked),
+		TotalRUnlocked: uint64(m.totalrUnlocked),
+		TotalWeight:    uint64(m.totalWeight),
+
+		WaitTotal:       f.waitTotal,
+		WaitMax:         f.waitMax,
+		WorstWaiter:     f.worstWaiter,
+		Contended:       uint64(f.contendedCount),
+		Uncontended:     uint64(f.uncontendedCount),
+		UnlockTimeTotal: f.unlockTimeTotal,
+		UnlockTimeMax:   f.unlockTimeMax,
+		TrivialHolds:    uint64(f.trivialHoldCount),
+		WriteErrors:     uint64(m.writeErrorCount),
+
+		TrackingEvictions: uint64(f.trackingEvictions),
+
+		FirstUse: f.firstUse,
+		LastUse:  f.lastUse,
+	}
+}
+
+// StatsEqual reports whether a and b have the same total counters,
+// ignoring the Name, the active locks and all timing values.
+//
+//	if !loggedrwmutex.StatsEqual(primary.Snapshot(), replica.Snapshot()) { ... }
+func StatsEqual(a, b Stats) bool {
+	return a.TotalLocked == b.TotalLocked &&
+		a.TotalUnlocked == b.TotalUnlocked &&
+		a.TotalRLocked == b.TotalRLocked &&
+		a.TotalRUnlocked == b.TotalRUnlocked
+}
+
+// MergeStats folds stats into one aggregate: totals and durations are summed,
+// the active locks and maxima take the largest value, FirstUse the earliest.
+// Name is empty, Group is kept if all stats share it.
+func MergeStats(stats ...Stats) Stats {
+	var sum Stats
+	for i, st := range stats {
+		if i == 0 {
+			sum.Group = st.Group
+		} else if st.Group != sum.Group {
+			sum.Group = ""
+		}
+		sum.Locked = max(sum.Locked, st.Locked)
+		sum.RLocked = max(sum.RLocked, st.RLocked)
+		sum.Waiters = max(sum.Waiters, st.Waiters)
+		sum.ReaderWaiters = max(sum.ReaderWaiters, st.ReaderWaiters)
+		sum.WriterWaiters = max(sum.WriterWaiters, st.WriterWaiters)
+		sum.TotalLocked += st.TotalLocked
+		sum.TotalUnlocked += st.TotalUnlocked
+		sum.TotalRLocked += st.TotalRLocked
+		sum.TotalRUnlocked += st.TotalRUnlocked
+		sum.TotalWeight += st.TotalWeight
+		sum.WaitTotal += st.WaitTotal
+		if st.WaitMax > sum.WaitMax {
+			sum.WaitMax, sum.WorstWaiter = st.WaitMax, st.WorstWaiter
+		}
+		sum.Contended += st.Contended
+		sum.Uncontended += st.Uncontended
+		sum.UnlockTimeTotal += st.UnlockTimeTotal
+		sum.UnlockTimeMax = max(sum.UnlockTimeMax, st.UnlockTimeMax)
+		sum.TrivialHolds += st.TrivialHolds
+		sum.WriteErrors += st.WriteErrors
+		sum.TrackingEvictions += st.TrackingEvictions
+		if !st.FirstUse.IsZero() && (sum.FirstUse.IsZero() || st.FirstUse.Before(sum.FirstUse)) {
+			sum.FirstUse = st.FirstUse
+		}
+		if st.LastUse.After(sum.LastUse) {
+			sum.LastUse = st.LastUse
+		}
+	}
+	return sum
+}
+
+// LockState is the coarse state of a mutex, see State.
+type LockState int8
+
+const (
+	StateIdle      LockState = iota // not held
+	StateReadOnly                   // held by readers only
+	StateWriting                    // held for writing
+	StateContended                  // held while other goroutines wait for it
+)
+
+var lockStateNames = [...]string{"Idle", "ReadOnly", "Writing", "Contended"}
+
+func (s LockState) String() string {
+	if int(s) < len(lockStateNames) {
+		return lockStateNames[s]
+	}
+	return fmt.Sprintf("LockState(%d)", s)
+}
+
+// State returns the current state of the mutex derived from the active locks and waiters.
+// A goroutine counts as waiter from entering Lock or RLock until it has acquired,
+// so an uncontended mutex may briefly appear contended.
+func (m *LoggedSyncRWMutex) State() LockState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	held := m.lockedCount > 0 || m.rLockedCount > 0
+	switch {
+	case held && m.waiters() > 0:
+		return StateContended
+	case m.lockedCount > 0:
+		return StateWriting
+	case m.rLockedCount > 0:
+		return StateReadOnly
+	}
+	return StateIdle
+}
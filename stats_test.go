@@ -0,0 +1,252 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestSnapshot"}
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RLock()
+	mux.RUnlock()
+
+	want := Stats{Name: "TestSnapshot", RLocked: 1, TotalLocked: 1, TotalUnlocked: 1, TotalRLocked: 2, TotalRUnlocked: 1, TotalWeight: 1}
+	if got := mux.Snapshot(); got != want {
+		t.Errorf("Snapshot should be %+v, got %+v", want, got)
+	}
+	mux.RUnlock()
+}
+
+func TestStatsEqual(t *testing.T) {
+	a := &LoggedSyncRWMutex{Name: "TestStatsEqualA", MeasureHold: true}
+	b := &LoggedSyncRWMutex{Name: "TestStatsEqualB"}
+	for _, mux := range []*LoggedSyncRWMutex{a, b} {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RUnlock()
+	}
+	if !StatsEqual(a.Snapshot(), b.Snapshot()) {
+		t.Errorf("StatsEqual should be true, got %+v and %+v", a.Snapshot(), b.Snapshot())
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+	if StatsEqual(a.Snapshot(), b.Snapshot()) {
+		t.Error("StatsEqual should be false after an extra RLock")
+	}
+}
+
+func TestMergeStats(t *testing.T) {
+	a := Stats{Name: "a", Group: "db", Locked: 1, TotalLocked: 5, TotalUnlocked: 4, TotalRLocked: 10, TotalRUnlocked: 10, WaitMax: 3}
+	b := Stats{Name: "b", Group: "db", RLocked: 3, TotalLocked: 2, TotalUnlocked: 2, TotalRLocked: 7, TotalRUnlocked: 4, WaitMax: 9}
+	c := Stats{Name: "c", Group: "db", RLocked: 1, TotalLocked: 1, TotalUnlocked: 1, TotalRLocked: 1, TotalRUnlocked: 0, WaitMax: 5}
+
+	want := Stats{Group: "db", Locked: 1, RLocked: 3, TotalLocked: 8, TotalUnlocked: 7, TotalRLocked: 18, TotalRUnlocked: 14, WaitMax: 9}
+	if got := MergeStats(a, b, c); got != want {
+		t.Errorf("MergeStats should be %+v, got %+v", want, got)
+	}
+
+	c.Group = "cache"
+	if got := MergeStats(a, b, c); got.Group != "" {
+		t.Errorf("Group of mixed stats should be empty, got %q", got.Group)
+	}
+	if got := MergeStats(); got != (Stats{}) {
+		t.Errorf("MergeStats without stats should be zero, got %+v", got)
+	}
+}
+
+func TestRecordUse(t *testing.T) {
+	clock := useFakeClock(t)
+	mux := &LoggedSyncRWMutex{Name: "TestRecordUse", RecordUse: true}
+	if st := mux.Snapshot(); !st.FirstUse.IsZero() || !st.LastUse.IsZero() {
+		t.Errorf("FirstUse and LastUse should be zero before use, got %+v", st)
+	}
+
+	start := clock.now()
+	mux.Lock()
+	clock.advance(time.Second)
+	mux.Unlock()
+	clock.advance(time.Second)
+	mux.RLock()
+	st := mux.Snapshot()
+	if !st.FirstUse.Equal(start) || !st.LastUse.Equal(start.Add(2*time.Second)) {
+		t.Errorf("FirstUse should be %v and LastUse %v, got %v and %v", start, start.Add(2*time.Second), st.FirstUse, st.LastUse)
+	}
+	clock.advance(time.Second)
+	mux.RUnlock()
+	st = mux.Snapshot()
+	if !st.FirstUse.Equal(start) || !st.LastUse.Equal(start.Add(3*time.Second)) {
+		t.Errorf("FirstUse should stay %v while LastUse advances to %v, got %v and %v", start, start.Add(3*time.Second), st.FirstUse, st.LastUse)
+	}
+}
+
+func TestLockWeighted(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestLockWeighted"}
+	for _, w := range []int{3, 0, 10} {
+		mux.LockWeighted(w)
+		mux.Unlock()
+	}
+	mux.Lock() // weight 1
+	mux.Unlock()
+	mux.RLock() // read locks carry no weight
+	mux.RUnlock()
+
+	st := mux.Snapshot()
+	if st.TotalWeight != 14 || st.TotalLocked != 4 {
+		t.Errorf("TotalWeight should be 14 over 4 locks, got %d over %d", st.TotalWeight, st.TotalLocked)
+	}
+
+	mux.LockWeighted(-1)
+	mux.Unlock()
+	if st := mux.Snapshot(); st.TotalWeight != 14 || !strings.Contains(buf.String(), "negative weight -1") {
+		t.Errorf("negative weight should be reported and not counted, got %d, %q", st.TotalWeight, buf.String())
+	}
+}
+
+func TestState(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestState"}
+	if s := mux.State(); s != StateIdle {
+		t.Errorf("State should be Idle, got %v", s)
+	}
+	mux.RLock()
+	mux.RLock()
+	if s := mux.State(); s != StateReadOnly {
+		t.Errorf("State should be ReadOnly, got %v", s)
+	}
+	mux.RUnlock()
+	mux.RUnlock()
+	mux.Lock()
+	if s := mux.State(); s != StateWriting {
+		t.Errorf("State should be Writing, got %v", s)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	deadline := time.Now().Add(time.Second)
+	for mux.State() != StateContended && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if s := mux.State(); s != StateContended || s.String() != "Contended" {
+		t.Errorf("State should be Contended with a waiting reader, got %v", s)
+	}
+	mux.Unlock()
+	<-done
+	if s := mux.State(); s != StateIdle {
+		t.Errorf("State should be Idle again, got %v", s)
+	}
+
+	// an abandoned LockCtx is no waiter anymore
+	mux.Lock()
+	if mux.LockTimeout(time.Millisecond) {
+		t.Fatal("LockTimeout should fail")
+	}
+	if s := mux.State(); s != StateWriting {
+		t.Errorf("State should be Writing after the timeout, got %v", s)
+	}
+	mux.Unlock()
+}
+
+func TestReaderWriterWaiters(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestReaderWriterWaiters"}
+	var wg sync.WaitGroup
+	wait := func(n int, fn func()) {
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn()
+			}()
+		}
+	}
+	waitFor := func(readers, writers uint64) Stats {
+		deadline := time.Now().Add(time.Second)
+		st := mux.Snapshot()
+		for (st.ReaderWaiters != readers || st.WriterWaiters != writers) && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+			st = mux.Snapshot()
+		}
+		return st
+	}
+
+	// readers waiting for the write lock
+	mux.Lock()
+	wait(3, func() { mux.RLock(); mux.RUnlock() })
+	if st := waitFor(3, 0); st.ReaderWaiters != 3 || st.WriterWaiters != 0 || st.Waiters != 3 {
+		t.Errorf("should count 3 waiting readers, got %+v", st)
+	}
+	mux.Unlock()
+	wg.Wait()
+
+	// writers waiting for a read lock
+	mux.RLock()
+	wait(2, func() { mux.Lock(); mux.Unlock() })
+	if st := waitFor(0, 2); st.ReaderWaiters != 0 || st.WriterWaiters != 2 || st.Waiters != 2 {
+		t.Errorf("should count 2 waiting writers, got %+v", st)
+	}
+	mux.RUnlock()
+	wg.Wait()
+
+	if st := mux.Snapshot(); st.ReaderWaiters != 0 || st.WriterWaiters != 0 || st.Waiters != 0 {
+		t.Errorf("no goroutine should wait anymore, got %+v", st)
+	}
+}
+
+func TestSnapshotAndReset(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestSnapshotAndReset", DebugInvariants: true}
+	PanicOnMisuse = true
+	defer func() { PanicOnMisuse = false }()
+
+	const workers, each = 4, 500
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < each; j++ {
+				mux.Lock()
+				mux.Unlock()
+				mux.RLock()
+				mux.RUnlock()
+			}
+		}()
+	}
+	var sum Stats
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		sum = MergeStats(sum, mux.SnapshotAndReset())
+	}
+	if sum.TotalLocked != workers*each || sum.TotalUnlocked != workers*each || sum.TotalRLocked != workers*each || sum.TotalRUnlocked != workers*each {
+		t.Errorf("intervals should add up to %d of each op, got %+v", workers*each, sum)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 0 || st.TotalRUnlocked != 0 {
+		t.Errorf("totals should be zero after the last reset, got %+v", st)
+	}
+
+	// a lock held across a reset is released in the next interval
+	mux.Lock()
+	st := mux.SnapshotAndReset()
+	mux.Unlock()
+	if next := mux.SnapshotAndReset(); st.TotalLocked != 1 || st.Locked != 1 || next.TotalLocked != 0 || next.TotalUnlocked != 1 {
+		t.Errorf("Lock should be counted before and Unlock after the reset, got %+v then %+v", st, next)
+	}
+}
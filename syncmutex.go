@@ -0,0 +1,152 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// LoggedSyncMutex is the sibling of LoggedSyncRWMutex for a plain sync.Mutex.
+// It counts and logs Lock and Unlock exactly like the write side of LoggedSyncRWMutex,
+// with the same log lines and Stats, without the read lock semantics.
+// It shares the counters and the log output of LoggedSyncRWMutex but none of its
+// debugging features. Of the package settings it follows GlobalDebug, DisableLogging,
+// PauseLogging, Output, NamePrefix, FieldSeparator, VersionTag, RelativeTimestamps,
+// IndentByDepth, JSONLines, MaxWriteErrors and MinWarnSeverity.
+// The fields are read on every operation.
+//
+//	mux := &loggedrwmutex.LoggedSyncMutex{Name: "ResourceMutex", DebugAll: true}
+//	mux.Lock()
+//	defer mux.Unlock()
+type LoggedSyncMutex struct {
+	lockCore    // internal mutex, counters and log output state, shared with LoggedSyncRWMutex
+	Name        string
+	DebugAll    bool      // if true, will print debug messages
+	DebugLock   DebugFlag // DebugOn/DebugOff force debug messages for Lock on/off, DebugDefault follows DebugAll
+	DebugUnlock DebugFlag // DebugOn/DebugOff force debug messages for Unlock on/off, DebugDefault follows DebugAll
+	Writer      io.Writer // if set, receives the log lines of this mutex instead of Output
+	sync.Mutex            // the actual mutex that will be used for locking
+}
+
+func (m *LoggedSyncMutex) Lock() {
+	if DisableLogging {
+		m.Mutex.Lock()
+		return
+	}
+	m.mu.Lock()
+	m.writerWaiters++
+	if m.lockedCount > 0 && debugEnabled(m.DebugLock, m.DebugAll) && !JSONLines {
+		m.logOp(opLock, 0, m.nameField(), "waiting", fmt.Sprintf("locked=%d", m.lockedCount), "rLocked=0")
+	}
+	m.mu.Unlock()
+
+	m.Mutex.Lock()
+
+	m.acquired(true)
+}
+
+// TryLock tries to lock the mutex and reports whether it succeeded,
+// only a successful attempt is counted and logged.
+func (m *LoggedSyncMutex) TryLock() bool {
+	if !m.Mutex.TryLock() {
+		return false
+	}
+	if !DisableLogging {
+		m.acquired(false)
+	}
+	return true
+}
+
+func (m *LoggedSyncMutex) Unlock() {
+	if !DisableLogging {
+		m.releasing()
+	}
+
+	m.Mutex.Unlock()
+}
+
+// acquired counts and logs a lock after the embedded mutex has been acquired,
+// waited is set if Lock counted the caller as waiter.
+func (m *LoggedSyncMutex) acquired(waited bool) {
+	var gid int64
+	if IndentByDepth {
+		gid = goid()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if waited {
+		m.writerWaiters--
+	}
+	m.lockedCount++
+	m.totalLocked++
+	m.seq++
+	var depth int
+	if gid != 0 {
+		depth = depthAcquired(gid)
+	}
+	if debugEnabled(m.DebugLock, m.DebugAll) {
+		m.logOp(opLock, depth, m.nameField(), m.lockedField(m.totalLocked))
+	}
+}
+
+// releasing counts and logs an unlock before the embedded mutex is released.
+func (m *LoggedSyncMutex) releasing() {
+	var gid int64
+	if IndentByDepth {
+		gid = goid()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var depth int
+	if gid != 0 {
+		depth = depthReleased(gid)
+	}
+	m.lockedCount--
+	m.totalUnlocked++
+	m.seq++
+	if debugEnabled(m.DebugUnlock, m.DebugAll) {
+		m.logOp(opUnlock, depth, m.nameField(), m.lockedField(m.totalUnlocked))
+	}
+}
+
+// logOp writes the log line of one operation like LoggedSyncRWMutex.logOp does
+// without the options of a LoggedSyncRWMutex.
+// Must be called with m.mu held.
+func (m *LoggedSyncMutex) logOp(op byte, depth int, fields ...string) {
+	w := Output
+	if m.Writer != nil {
+		w = m.Writer
+	}
+	var err error
+	if JSONLines {
+		err = m.writeJSON(w, op, NamePrefix+m.Name, 0)
+	} else {
+		err = m.writef(w, "%s\n", opLine(op, depth, false, false, fields))
+	}
+	if msg := m.writeFailed(err); msg != "" && SeverityWarn >= MinWarnSeverity {
+		writeWarning(false, NamePrefix+m.Name, SeverityWarn, "write-error", msg, now())
+	}
+}
+
+// nameField formats the quoted name of m as log field.
+// Must be called with m.mu held.
+func (m *LoggedSyncMutex) nameField() string {
+	return "'" + NamePrefix + m.Name + "'"
+}
+
+// Snapshot returns a consistent copy of the counters, see LoggedSyncRWMutex.Snapshot.
+// Every lock has the weight 1.
+func (m *LoggedSyncMutex) Snapshot() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Stats{
+		Name:          m.Name,
+		Locked:        uint64(m.lockedCount),
+		Waiters:       uint64(m.writerWaiters),
+		WriterWaiters: uint64(m.writerWaiters),
+		TotalLocked:   uint64(m.totalLocked),
+		TotalUnlocked: uint64(m.totalUnlocked),
+		TotalWeight:   uint64(m.totalLocked),
+		WriteErrors:   uint64(m.writeErrorCount),
+	}
+}
@@ -0,0 +1,83 @@
+package loggedrwmutex
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestLoggedSyncMutex(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncMutex{Name: "TestLoggedSyncMutex", DebugAll: true}
+	mux.Lock()
+	mux.Unlock()
+	if !mux.TryLock() {
+		t.Fatal("TryLock should succeed on a free mutex")
+	}
+	if mux.TryLock() {
+		t.Fatal("TryLock should fail while locked")
+	}
+	if st := mux.Snapshot(); st.Name != "TestLoggedSyncMutex" || st.Locked != 1 || st.TotalLocked != 2 || st.Waiters != 0 {
+		t.Errorf("failed TryLock should not be counted, got %+v", st)
+	}
+	mux.Unlock()
+	got := lines(buf)
+
+	// the same operations on the write side of a LoggedSyncRWMutex
+	buf.Reset()
+	rw := &LoggedSyncRWMutex{Name: "TestLoggedSyncMutex", DebugAll: true}
+	rw.Lock()
+	rw.Unlock()
+	rw.Lock()
+	rw.Unlock()
+	want := lines(buf)
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("LoggedSyncMutex should log like LoggedSyncRWMutex %q, got %q", want, got)
+	}
+	if len(want) != 4 || want[0] != "[loggedMUTEX] Lock 'TestLoggedSyncMutex' locked=1/1" || want[3] != "[loggedMUTEX] Unlock 'TestLoggedSyncMutex' locked=0/2" {
+		t.Errorf("unexpected log lines %q", want)
+	}
+	if st, wst := mux.Snapshot(), rw.Snapshot(); !StatsEqual(st, wst) {
+		t.Errorf("counters should match %+v, got %+v", wst, st)
+	}
+
+	// without debug nothing is logged, counting continues
+	buf.Reset()
+	quiet := &LoggedSyncMutex{Name: "TestLoggedSyncMutexQuiet"}
+	quiet.Lock()
+	quiet.Unlock()
+	if buf.Len() != 0 {
+		t.Errorf("should not log without debug, got %q", buf.String())
+	}
+	if st := quiet.Snapshot(); st.TotalLocked != 1 || st.TotalUnlocked != 1 {
+		t.Errorf("should count without debug, got %+v", st)
+	}
+}
+
+func TestLoggedSyncMutexConfig(t *testing.T) {
+	buf := captureOutput(t)
+	mux := &LoggedSyncMutex{Name: "TestLoggedSyncMutexConfig"}
+	mux.Lock()
+	mux.Unlock()
+	// the fields are read on every operation, not only on the first
+	mux.DebugAll = true
+	mux.Name = "TestLoggedSyncMutexConfigRenamed"
+	mux.Lock()
+	mux.DebugUnlock = DebugOff
+	mux.Unlock()
+	if got := lines(buf); len(got) != 1 || got[0] != "[loggedMUTEX] Lock 'TestLoggedSyncMutexConfigRenamed' locked=1/2" {
+		t.Errorf("should follow changed fields, got %q", got)
+	}
+
+	var w strings.Builder
+	mux.Writer = &w
+	mux.Lock()
+	mux.Unlock()
+	if buf.Len() != len("[loggedMUTEX] Lock 'TestLoggedSyncMutexConfigRenamed' locked=1/2\n") || !strings.Contains(w.String(), "locked=1/3") {
+		t.Errorf("should log to a Writer set later, got %q and %q", buf.String(), w.String())
+	}
+
+	if sm, rw := unsafe.Sizeof(LoggedSyncMutex{}), unsafe.Sizeof(LoggedSyncRWMutex{}); sm >= rw/2 {
+		t.Errorf("LoggedSyncMutex should be much smaller than LoggedSyncRWMutex, got %d and %d bytes", sm, rw)
+	}
+}
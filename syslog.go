@@ -0,0 +1,23 @@
+//go:build !windows && !plan9
+
+package loggedrwmutex
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// syslogDial connects to the syslog daemon, replaced in tests.
+var syslogDial = func(tag string) (io.Writer, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_USER, tag)
+}
+
+// NewSyslogWriter returns a writer that sends each log line to the local syslog daemon
+// with priority LOG_INFO|LOG_USER. It can be assigned to Output, WarnOutput or a mutex Writer:
+//
+//	w, err := loggedrwmutex.NewSyslogWriter("myapp")
+//	if err != nil { ... }
+//	loggedrwmutex.Output = w
+func NewSyslogWriter(tag string) (io.Writer, error) {
+	return syslogDial(tag)
+}
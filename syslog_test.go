@@ -0,0 +1,46 @@
+//go:build !windows && !plan9
+
+package loggedrwmutex
+
+import (
+	"io"
+	"log/syslog"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSyslogWriter(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets not available: %v", err)
+	}
+	defer conn.Close()
+
+	defer func(dial func(string) (io.Writer, error)) { syslogDial = dial }(syslogDial)
+	syslogDial = func(tag string) (io.Writer, error) {
+		return syslog.Dial("unixgram", addr, syslog.LOG_INFO|syslog.LOG_USER, tag)
+	}
+	w, err := NewSyslogWriter("loggedtest")
+	if err != nil {
+		t.Fatalf("NewSyslogWriter failed: %v", err)
+	}
+
+	mux := &LoggedSyncRWMutex{Name: "TestNewSyslogWriter", DebugLock: DebugOn, Writer: w}
+	mux.Lock()
+	mux.Unlock()
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no syslog message received: %v", err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<14>") || !strings.Contains(msg, "loggedtest") || !strings.Contains(msg, "[loggedMUTEX] Lock 'TestNewSyslogWriter'") {
+		t.Errorf("syslog message should contain the priority, tag and lock line, got %q", msg)
+	}
+}
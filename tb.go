@@ -0,0 +1,77 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"sync"
+)
+
+// TB is the part of testing.TB used by LogToTB and AutoCheckLeaks.
+type TB interface {
+	Log(args ...any)
+	Errorf(format string, args ...any)
+	Cleanup(f func())
+}
+
+// LogToTB sends the log lines of the mutex to t.Log, one call per line,
+// so they are attached to the test and only shown on failure or with -v.
+// The previous Writer is restored when the test ends.
+//
+//	mux.LogToTB(t)
+func (m *LoggedSyncRWMutex) LogToTB(t TB) {
+	m.mu.Lock()
+	prev := m.Writer
+	m.Writer = &tbWriter{tb: t}
+	m.mu.Unlock()
+	t.Cleanup(func() {
+		m.mu.Lock()
+		m.Writer = prev
+		m.mu.Unlock()
+	})
+}
+
+// AutoCheckLeaks makes the test fail if the mutex is still locked or read locked
+// when the test ends.
+//
+//	mux.AutoCheckLeaks(t)
+func (m *LoggedSyncRWMutex) AutoCheckLeaks(t TB) {
+	t.Cleanup(func() { checkLeaks(t, m) })
+}
+
+// AutoCheckAllLeaks makes the test fail if any registered mutex is still locked
+// or read locked when the test ends.
+func AutoCheckAllLeaks(t TB) {
+	t.Cleanup(func() {
+		for _, m := range registered() {
+			checkLeaks(t, m)
+		}
+	})
+}
+
+// checkLeaks reports m to t if it is held.
+func checkLeaks(t TB, m *LoggedSyncRWMutex) {
+	if st := m.Snapshot(); st.Locked > 0 || st.RLocked > 0 {
+		t.Errorf("loggedrwmutex: '%s' still held at the end of the test: locked=%d rLocked=%d", NamePrefix+st.Name, st.Locked, st.RLocked)
+	}
+}
+
+// tbWriter buffers written bytes and passes every complete line to tb.Log.
+type tbWriter struct {
+	mu  sync.Mutex
+	tb  TB
+	buf []byte
+}
+
+func (w *tbWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.tb.Log(string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
@@ -0,0 +1,98 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeTB records the Log and Errorf calls and Cleanup funcs of a test.
+type fakeTB struct {
+	logs     []string
+	errors   []string
+	cleanups []func()
+}
+
+func (tb *fakeTB) Log(args ...any) { tb.logs = append(tb.logs, fmt.Sprint(args...)) }
+
+func (tb *fakeTB) Errorf(format string, args ...any) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Cleanup(f func()) { tb.cleanups = append(tb.cleanups, f) }
+
+func TestLogToTB(t *testing.T) {
+	out := captureOutput(t)
+	tb := &fakeTB{}
+	mux := &LoggedSyncRWMutex{Name: "TestLogToTB", DebugAll: true}
+	mux.LogToTB(tb)
+
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	if len(tb.logs) != 4 {
+		t.Fatalf("should call Log once per line, got %q", tb.logs)
+	}
+	for i, op := range []string{"Lock", "Unlock", "RLock", "RUnlock"} {
+		if !strings.HasPrefix(tb.logs[i], "[loggedMUTEX] "+op+" ") || strings.HasSuffix(tb.logs[i], "\n") {
+			t.Errorf("Log call %d should be the %s line without newline, got %q", i, op, tb.logs[i])
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("Output should not receive lines, got %q", out.String())
+	}
+
+	// partial writes are joined to one line
+	w := &tbWriter{tb: tb}
+	fmt.Fprint(w, "part")
+	fmt.Fprint(w, "ial\nnext\n")
+	if got := tb.logs[4:]; len(got) != 2 || got[0] != "partial" || got[1] != "next" {
+		t.Errorf("tbWriter should log complete lines, got %q", got)
+	}
+
+	// cleanup restores Output
+	tb.cleanup()
+	mux.Lock()
+	mux.Unlock()
+	if n := len(lines(out)); n != 2 {
+		t.Errorf("Output should receive lines after cleanup, got %d", n)
+	}
+}
+
+// cleanup runs the registered cleanups in reverse order like testing.T.
+func (tb *fakeTB) cleanup() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+func TestAutoCheckLeaks(t *testing.T) {
+	tb := &fakeTB{}
+	leaked := &LoggedSyncRWMutex{Name: "TestAutoCheckLeaks"}
+	clean := &LoggedSyncRWMutex{Name: "TestAutoCheckLeaksClean"}
+	leaked.AutoCheckLeaks(tb)
+	clean.AutoCheckLeaks(tb)
+
+	leaked.RLock()
+	clean.Lock()
+	clean.Unlock()
+	tb.cleanup()
+	want := "loggedrwmutex: 'TestAutoCheckLeaks' still held at the end of the test: locked=0 rLocked=1"
+	if len(tb.errors) != 1 || tb.errors[0] != want {
+		t.Errorf("cleanup should report %q, got %q", want, tb.errors)
+	}
+	leaked.RUnlock()
+
+	useRegistry(t)
+	tb = &fakeTB{}
+	Register(leaked)
+	Register(clean)
+	AutoCheckAllLeaks(tb)
+	clean.Lock()
+	tb.cleanup()
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "'TestAutoCheckLeaksClean' still held") {
+		t.Errorf("cleanup should report the held registered mutex, got %q", tb.errors)
+	}
+	clean.Unlock()
+}
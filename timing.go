@@ -0,0 +1,258 @@
+package loggedrwmutex
+
+import (
+	"time"
+)
+
+// ContentionMode selects how MeasureContention measures contention.
+type ContentionMode int8
+
+const (
+	ContentionTimed    ContentionMode = iota // measure the wait of every acquisition, see AvgWait
+	ContentionTryFirst                       // only count acquisitions that find the lock taken, without timestamps
+)
+
+// Waiter identifies the goroutine and call site of an acquisition, see Stats.WorstWaiter.
+type Waiter struct {
+	Goroutine int64
+	Caller    string // file:line of the Lock or RLock call
+}
+
+// waitDone accumulates the wait of one acquisition by goroutine gid,
+// 0 if not known yet.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) waitDone(d time.Duration, gid int64) {
+	f := m.feat()
+	f.waitTotal += d
+	f.waitCount++
+	if d > f.waitMax {
+		if gid == 0 {
+			gid = goid()
+		}
+		f.waitMax, f.worstWaiter = d, Waiter{Goroutine: gid, Caller: caller()}
+	}
+}
+
+// used records an operation at t for RecordUse, t may be zero if not taken yet.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) used(t time.Time) {
+	if t.IsZero() {
+		t = now()
+	}
+	f := m.feat()
+	if f.firstUse.IsZero() {
+		f.firstUse = t
+	}
+	f.lastUse = t
+}
+
+// holdSampled reports whether the next hold is timed according to HoldSampleEvery.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) holdSampled() bool {
+	if m.HoldSampleEvery <= 1 {
+		return true
+	}
+	f := m.feat()
+	f.holdSampleSeq++
+	return f.holdSampleSeq%m.HoldSampleEvery == 0
+}
+
+// holdStarted records the acquisition time of a hold if sampled,
+// t is zero for holds not sampled.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) holdStarted(t time.Time, write, sampled bool) {
+	f := m.feat()
+	if t.IsZero() && (f.measureStart.IsZero() || m.lockedCount+m.rLockedCount == 1) {
+		t = now()
+	}
+	if f.measureStart.IsZero() {
+		f.measureStart = t
+	}
+	if m.lockedCount+m.rLockedCount == 1 {
+		f.busyStart = t
+	}
+	if !sampled {
+		return
+	}
+	if write {
+		f.holdStart = t
+		return
+	}
+	f.rHoldStarts = append(f.rHoldStarts, t)
+}
+
+// holdDone accumulates the duration of a finished hold and returns it, false if the hold was not timed.
+// Read unlocks can not be matched with their read lock, so they are matched
+// with the oldest active one. The accumulated total, and so the average,
+// stays exact as the sum of all releases minus all acquisitions is the same
+// for any matching. With HoldSampleEvery one in N read unlocks ends a sampled
+// read hold, or any read unlock once only sampled read holds are left.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) holdDone(t time.Time, write bool) (time.Duration, bool) {
+	f := m.feat()
+	if m.lockedCount+m.rLockedCount == 1 && !f.busyStart.IsZero() {
+		f.busyTotal += t.Sub(f.busyStart)
+		f.busyStart = time.Time{}
+	}
+	var start time.Time
+	if write {
+		start, f.holdStart = f.holdStart, time.Time{}
+	} else if n := uint64(len(f.rHoldStarts)); n > 0 && (n >= uint64(m.rLockedCount) || m.HoldSampleEvery > 1 && (uint64(m.totalrUnlocked)+1)%m.HoldSampleEvery == 0) {
+		start = f.rHoldStarts[0]
+		f.rHoldStarts = f.rHoldStarts[1:]
+	}
+	if start.IsZero() {
+		// acquired before MeasureHold was enabled
+		return 0, false
+	}
+	d := t.Sub(start)
+	f.holdTotal += d
+	f.holdCount++
+	m.holdSample(d)
+	if d < m.TrivialHoldThreshold {
+		f.trivialHoldCount++
+	}
+	if m.TrivialHoldWarnRatio > 0 && f.holdCount%trivialHoldCheckEvery == 0 {
+		if ratio := float64(f.trivialHoldCount) / float64(f.holdCount); ratio > m.TrivialHoldWarnRatio {
+			m.warnf(SeverityInfo, "trivial-hold", "%d of %d holds (ratio %s) shorter than %v, lock may be taken in a hot loop", f.trivialHoldCount, f.holdCount, formatFloat(ratio), m.TrivialHoldThreshold)
+		}
+	}
+	return d, true
+}
+
+// trivialHoldCheckEvery is the number of holds between TrivialHoldWarnRatio checks.
+const trivialHoldCheckEvery = 100
+
+// Defaults of DetectInversion.
+const (
+	defaultInversionHold    = 100 * time.Millisecond
+	defaultInversionWaiters = 2
+)
+
+// checkInversion warns if a hold of d kept enough goroutines waiting to hint at a priority inversion:
+// the holder likely blocks on I/O or something slow while others queue up.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) checkInversion(d time.Duration) {
+	threshold, waiters := m.InversionHold, m.InversionWaiters
+	if threshold <= 0 {
+		threshold = defaultInversionHold
+	}
+	if waiters <= 0 {
+		waiters = defaultInversionWaiters
+	}
+	if n := m.waiters(); d >= threshold && uint64(n) >= uint64(waiters) {
+		m.warnf(SeverityWarn, "priority-inversion", "possible priority inversion: held %v while %d goroutines waited", d, n)
+	}
+}
+
+// unlockDone accumulates the duration of one embedded Unlock or RUnlock call,
+// which can take a while with waiting writers.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) unlockDone(d time.Duration) {
+	f := m.feat()
+	f.unlockTimeTotal += d
+	if d > f.unlockTimeMax {
+		f.unlockTimeMax = d
+	}
+}
+
+// AvgHold returns the average duration locks and read locks were held.
+// Requires MeasureHold, returns 0 if no hold has completed yet.
+func (m *LoggedSyncRWMutex) AvgHold() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	if f.holdCount == 0 {
+		return 0
+	}
+	return f.holdTotal / time.Duration(f.holdCount)
+}
+
+// AvgWait returns the average time Lock and RLock waited for the lock.
+// Requires MeasureContention, returns 0 if no acquisition was measured yet.
+func (m *LoggedSyncRWMutex) AvgWait() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	if f.waitCount == 0 {
+		return 0
+	}
+	return f.waitTotal / time.Duration(f.waitCount)
+}
+
+// Utilization returns the fraction of time since the first measured acquisition
+// the mutex was held by anyone. Overlapping read locks count once: the mutex is busy
+// from the acquisition that finds it free until the release that leaves it free.
+// Requires MeasureHold, returns 0 before the first acquisition.
+func (m *LoggedSyncRWMutex) Utilization() float64 {
+	busy, elapsed := m.busy(now())
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(busy) / float64(elapsed)
+}
+
+// busy returns the time the mutex was held and the time elapsed
+// from the first measured acquisition up to t, both 0 before it.
+func (m *LoggedSyncRWMutex) busy(t time.Time) (busy, elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.view()
+	if f.measureStart.IsZero() {
+		return 0, 0
+	}
+	busy = f.busyTotal
+	if !f.busyStart.IsZero() {
+		busy += t.Sub(f.busyStart)
+	}
+	return busy, t.Sub(f.measureStart)
+}
+
+// GlobalUtilization returns the Utilization of all registered mutexes together:
+// the sum of their held times divided by the sum of their measured times,
+// so long measured mutexes weigh more. Requires MeasureHold, mutexes without
+// a measured acquisition are skipped. Returns 0 if there are none.
+func GlobalUtilization() float64 {
+	t := now()
+	var busy, elapsed time.Duration
+	for _, m := range registered() {
+		b, e := m.busy(t)
+		busy += b
+		elapsed += e
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(busy) / float64(elapsed)
+}
+
+// WasContended reports whether any acquisition ever found the lock taken.
+// Requires MeasureContention with ContentionTryFirst, the timed mode can not
+// tell a short wait from none.
+func (m *LoggedSyncRWMutex) WasContended() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.view().contendedCount > 0
+}
+
+// CompareReadWrite runs fn iters times under RLock and iters times under Lock
+// and returns the average duration of one iteration including the lock operations.
+// It is a diagnostic helper for comparing the cost of read and write locking,
+// the operations are counted and logged like any other. Returns 0, 0 if iters < 1.
+func (m *LoggedSyncRWMutex) CompareReadWrite(fn func(), iters int) (readAvg, writeAvg time.Duration) {
+	if iters < 1 {
+		return 0, 0
+	}
+	run := func(lock, unlock func()) time.Duration {
+		start := now()
+		for i := 0; i < iters; i++ {
+			lock()
+			fn()
+			unlock()
+		}
+		return now().Sub(start) / time.Duration(iters)
+	}
+	readAvg = run(m.RLock, m.RUnlock)
+	writeAvg = run(m.Lock, m.Unlock)
+	return readAvg, writeAvg
+}
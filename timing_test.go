@@ -0,0 +1,479 @@
+package loggedrwmutex
+
+import (
+	"context"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAvgHold(t *testing.T) {
+	clock := useFakeClock(t)
+	mux := &LoggedSyncRWMutex{Name: "TestAvgHold", MeasureHold: true}
+	if avg := mux.AvgHold(); avg != 0 {
+		t.Errorf("AvgHold should be 0 without holds, got %v", avg)
+	}
+
+	for _, d := range []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 60 * time.Millisecond} {
+		mux.Lock()
+		clock.advance(d)
+		mux.Unlock()
+	}
+	if avg := mux.AvgHold(); avg != 30*time.Millisecond {
+		t.Errorf("AvgHold should be 30ms, got %v", avg)
+	}
+
+	// overlapping readers: 10ms and 30ms
+	mux.RLock()
+	clock.advance(10 * time.Millisecond)
+	mux.RLock()
+	clock.advance(10 * time.Millisecond)
+	mux.RUnlock()
+	clock.advance(10 * time.Millisecond)
+	mux.RUnlock()
+	// (10+20+60+20+20) / 5 holds
+	if avg := mux.AvgHold(); avg != 26*time.Millisecond {
+		t.Errorf("AvgHold should be 26ms, got %v", avg)
+	}
+}
+
+func TestAvgWait(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestAvgWait", MeasureContention: true}
+	if avg := mux.AvgWait(); avg != 0 {
+		t.Errorf("AvgWait should be 0 without acquisitions, got %v", avg)
+	}
+
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Lock() // waits until released below
+		mux.Unlock()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	// two acquisitions, one waited at least ~20ms
+	if avg := mux.AvgWait(); avg < 5*time.Millisecond {
+		t.Errorf("AvgWait should reflect the contended acquisition, got %v", avg)
+	}
+}
+
+func TestUnlockTime(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestUnlockTime", MeasureHold: true}
+	mux.Lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mux.Lock()
+			mux.Unlock()
+			mux.RLock()
+			mux.RUnlock()
+		}()
+	}
+	time.Sleep(20 * time.Millisecond) // let the waiters queue up
+	mux.Unlock()
+	wg.Wait()
+
+	st := mux.Snapshot()
+	if st.UnlockTimeTotal <= 0 || st.UnlockTimeMax <= 0 {
+		t.Errorf("unlock time should be populated, got total=%v max=%v", st.UnlockTimeTotal, st.UnlockTimeMax)
+	}
+	if st.UnlockTimeMax > st.UnlockTimeTotal {
+		t.Errorf("unlock time max %v should not exceed total %v", st.UnlockTimeMax, st.UnlockTimeTotal)
+	}
+}
+
+func TestTrivialHolds(t *testing.T) {
+	clock := useFakeClock(t)
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestTrivialHolds", MeasureHold: true, TrivialHoldThreshold: time.Millisecond}
+
+	for i := 0; i < 10; i++ {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RUnlock()
+	}
+	mux.Lock()
+	clock.advance(10 * time.Millisecond)
+	mux.Unlock()
+	if n := mux.Snapshot().TrivialHolds; n != 20 {
+		t.Errorf("TrivialHolds should be 20, got %d", n)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("should not warn without TrivialHoldWarnRatio, got %q", buf.String())
+	}
+
+	mux.TrivialHoldWarnRatio = 0.5
+	for i := 0; i < 100; i++ {
+		mux.Lock()
+		mux.Unlock()
+	}
+	if got := lines(buf); len(got) != 1 || !strings.Contains(got[0], "trivial-hold") {
+		t.Errorf("should warn once about trivial holds, got %q", got)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	clock := useFakeClock(t)
+	mux := &LoggedSyncRWMutex{Name: "TestUtilization", MeasureHold: true}
+	if u := mux.Utilization(); u != 0 {
+		t.Errorf("Utilization should be 0 before use, got %v", u)
+	}
+
+	mux.Lock()
+	clock.advance(30 * time.Millisecond)
+	mux.Unlock()
+	clock.advance(70 * time.Millisecond)
+	if u := mux.Utilization(); u != 0.3 {
+		t.Errorf("Utilization should be 0.3, got %v", u)
+	}
+
+	// overlapping readers are busy for 30ms, not 40ms
+	mux.RLock()
+	clock.advance(10 * time.Millisecond)
+	mux.RLock()
+	clock.advance(10 * time.Millisecond)
+	mux.RUnlock()
+	clock.advance(10 * time.Millisecond)
+	mux.RUnlock()
+	clock.advance(70 * time.Millisecond)
+	if u := mux.Utilization(); u != 0.3 {
+		t.Errorf("Utilization should be 0.3 with overlapping readers, got %v", u)
+	}
+
+	// a held lock counts up to now
+	mux.Lock()
+	clock.advance(200 * time.Millisecond)
+	if u := mux.Utilization(); u != 0.65 {
+		t.Errorf("Utilization should be 0.65 while held, got %v", u)
+	}
+	mux.Unlock()
+}
+
+func TestGlobalUtilization(t *testing.T) {
+	useRegistry(t)
+	clock := useFakeClock(t)
+	if u := GlobalUtilization(); u != 0 {
+		t.Errorf("GlobalUtilization should be 0 without mutexes, got %v", u)
+	}
+	a := Register(&LoggedSyncRWMutex{Name: "TestGlobalUtilizationA", MeasureHold: true})
+	b := Register(&LoggedSyncRWMutex{Name: "TestGlobalUtilizationB", MeasureHold: true})
+	Register(&LoggedSyncRWMutex{Name: "TestGlobalUtilizationUnmeasured"})
+
+	// a is held 1s of 4s, b 3s of 3s
+	a.Lock()
+	clock.advance(time.Second)
+	a.Unlock()
+	b.Lock()
+	clock.advance(3 * time.Second)
+	b.Unlock()
+	if u, want := GlobalUtilization(), 4.0/7.0; u != want {
+		t.Errorf("GlobalUtilization should be %v, got %v", want, u)
+	}
+
+	// a held lock counts up to now: a 2s of 5s, b 3s of 4s
+	a.RLock()
+	clock.advance(time.Second)
+	if u, want := GlobalUtilization(), 5.0/9.0; u != want {
+		t.Errorf("GlobalUtilization should be %v with a held lock, got %v", want, u)
+	}
+	a.RUnlock()
+}
+
+func TestContentionTryFirst(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestContentionTryFirst", MeasureContention: true, ContentionMode: ContentionTryFirst}
+
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RLock() // readers do not contend with each other
+	mux.RUnlock()
+	mux.RUnlock()
+	if n := mux.Snapshot().Contended; n != 0 {
+		t.Errorf("uncontended acquisitions should not be counted, got %d", n)
+	}
+
+	// a reader waiting for the write lock and a writer waiting for a read lock
+	contend := func(hold func(), release func(), wait func()) {
+		hold()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			wait()
+		}()
+		time.Sleep(20 * time.Millisecond)
+		release()
+		<-done
+	}
+	contend(mux.Lock, mux.Unlock, func() { mux.RLock(); mux.RUnlock() })
+	contend(mux.RLock, mux.RUnlock, func() { mux.Lock(); mux.Unlock() })
+
+	st := mux.Snapshot()
+	if st.Contended != 2 {
+		t.Errorf("Contended should be 2, got %d", st.Contended)
+	}
+	if st.WaitTotal != 0 || mux.view().waitCount != 0 {
+		t.Errorf("ContentionTryFirst should not time waits, got WaitTotal=%v waitCount=%d", st.WaitTotal, mux.view().waitCount)
+	}
+}
+
+func TestUncontended(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestUncontended", MeasureContention: true, ContentionMode: ContentionTryFirst}
+	for i := 0; i < 10; i++ {
+		mux.Lock()
+		mux.Unlock()
+		mux.RLock()
+		mux.RUnlock()
+	}
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock()
+		mux.RUnlock()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	st := mux.Snapshot()
+	if st.Uncontended != 21 || st.Contended != 1 {
+		t.Errorf("should count 21 uncontended and 1 contended acquisitions, got %d and %d", st.Uncontended, st.Contended)
+	}
+
+	// the timed mode does not try first
+	timed := &LoggedSyncRWMutex{Name: "TestUncontendedTimed", MeasureContention: true}
+	timed.Lock()
+	timed.Unlock()
+	if n := timed.Snapshot().Uncontended; n != 0 {
+		t.Errorf("ContentionTimed should not count uncontended acquisitions, got %d", n)
+	}
+}
+
+func TestUncontendedOtherPaths(t *testing.T) {
+	contend := func(t *testing.T, acquire func(mux *LoggedSyncRWMutex)) Stats {
+		mux := &LoggedSyncRWMutex{Name: t.Name(), MeasureContention: true, ContentionMode: ContentionTryFirst}
+		acquire(mux) // free
+		mux.Unlock()
+		mux.Lock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			acquire(mux)
+			mux.Unlock()
+		}()
+		for mux.Snapshot().Waiters != 1 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		mux.Unlock()
+		<-done
+		return mux.Snapshot()
+	}
+	t.Run("LockCtx", func(t *testing.T) {
+		st := contend(t, func(mux *LoggedSyncRWMutex) {
+			if err := mux.LockCtx(context.Background()); err != nil {
+				t.Error(err)
+			}
+		})
+		if st.Contended != 1 || st.Uncontended != 2 {
+			t.Errorf("a blocked LockCtx should be contended, got Contended=%d Uncontended=%d", st.Contended, st.Uncontended)
+		}
+	})
+	t.Run("LockLabeled", func(t *testing.T) {
+		st := contend(t, func(mux *LoggedSyncRWMutex) { mux.LockLabeled("flush") })
+		if st.Contended != 1 || st.Uncontended != 2 {
+			t.Errorf("a blocked LockLabeled should be contended, got Contended=%d Uncontended=%d", st.Contended, st.Uncontended)
+		}
+	})
+}
+
+func TestOnHold(t *testing.T) {
+	clock := useFakeClock(t)
+	type hold struct {
+		op string
+		d  time.Duration
+	}
+	var got []hold
+	mux := &LoggedSyncRWMutex{Name: "TestOnHold", MeasureHold: true}
+	mux.OnHold = func(op string, d time.Duration) {
+		got = append(got, hold{op, d})
+		// runs outside of the internal lock
+		_ = mux.Snapshot()
+	}
+
+	mux.Lock()
+	clock.advance(10 * time.Millisecond)
+	mux.Unlock()
+	mux.RLock()
+	clock.advance(2 * time.Millisecond)
+	mux.RLock()
+	clock.advance(2 * time.Millisecond)
+	mux.RUnlock()
+	clock.advance(2 * time.Millisecond)
+	mux.RUnlock()
+
+	want := []hold{{"Unlock", 10 * time.Millisecond}, {"RUnlock", 4 * time.Millisecond}, {"RUnlock", 4 * time.Millisecond}}
+	if !slices.Equal(got, want) {
+		t.Errorf("OnHold should be called once per hold with %v, got %v", want, got)
+	}
+
+	// without MeasureHold nothing is timed
+	got = nil
+	mux.MeasureHold = false
+	mux.Lock()
+	mux.Unlock()
+	if len(got) != 0 {
+		t.Errorf("OnHold should not be called without MeasureHold, got %v", got)
+	}
+}
+
+func TestWorstWaiter(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestWorstWaiter", MeasureContention: true}
+	type waiter struct {
+		gid  int64
+		line int
+	}
+	// wait lets a goroutine wait for the lock for about d
+	wait := func(d time.Duration) waiter {
+		mux.Lock()
+		ch := make(chan waiter, 1)
+		go func() {
+			_, _, line, _ := runtime.Caller(0)
+			mux.Lock()
+			ch <- waiter{goid(), line + 1}
+			mux.Unlock()
+		}()
+		for mux.State() != StateContended {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(d)
+		mux.Unlock()
+		return <-ch
+	}
+	worst := wait(30 * time.Millisecond)
+	wait(0)
+
+	st := mux.Snapshot()
+	want := Waiter{Goroutine: worst.gid, Caller: "timing_test.go:" + strconv.Itoa(worst.line)}
+	if st.WorstWaiter != want || st.WaitMax < 30*time.Millisecond {
+		t.Errorf("WorstWaiter should be %+v with WaitMax >= 30ms, got %+v after %v", want, st.WorstWaiter, st.WaitMax)
+	}
+	if worst.gid == goid() {
+		t.Error("WorstWaiter should not be the test goroutine")
+	}
+}
+
+func TestWorstWaiterLockTimeout(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestWorstWaiterLockTimeout", MeasureContention: true}
+	mux.Lock()
+	ch := make(chan int, 1)
+	go func() {
+		_, _, line, _ := runtime.Caller(0)
+		mux.LockTimeout(time.Second)
+		mux.Unlock()
+		ch <- line + 1
+	}()
+	for mux.State() != StateContended {
+		time.Sleep(time.Millisecond)
+	}
+	mux.Unlock()
+	want := "timing_test.go:" + strconv.Itoa(<-ch)
+	if got := mux.Snapshot().WorstWaiter.Caller; got != want {
+		t.Errorf("WorstWaiter of LockTimeout should be at %s, got %q", want, got)
+	}
+}
+
+func TestWasContended(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestWasContended", MeasureContention: true, ContentionMode: ContentionTryFirst}
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	if mux.WasContended() {
+		t.Error("WasContended should be false for an uncontended mutex")
+	}
+
+	mux.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Lock()
+		mux.Unlock()
+	}()
+	for mux.State() != StateContended {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond) // from waiting to the failed TryLock
+	mux.Unlock()
+	<-done
+	if !mux.WasContended() {
+		t.Error("WasContended should be true after a goroutine waited")
+	}
+}
+
+func TestDetectInversion(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestDetectInversion", MeasureHold: true, DetectInversion: true, InversionHold: 20 * time.Millisecond, InversionWaiters: 3}
+
+	// a long hold nobody waits for
+	mux.Lock()
+	time.Sleep(25 * time.Millisecond)
+	mux.Unlock()
+	if buf.Len() != 0 {
+		t.Fatalf("a long hold without waiters should not warn, got %q", buf.String())
+	}
+
+	mux.Lock()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mux.RLock()
+			mux.RUnlock()
+		}()
+	}
+	for mux.Snapshot().Waiters != 3 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(25 * time.Millisecond)
+	mux.Unlock()
+	wg.Wait()
+
+	got := lines(buf)
+	if len(got) != 1 || !strings.Contains(got[0], "[loggedMUTEX] WARN 'TestDetectInversion' priority-inversion: possible priority inversion: held") || !strings.Contains(got[0], "while 3 goroutines waited") {
+		t.Errorf("should hint at a priority inversion once, got %q", got)
+	}
+}
+
+func TestCompareReadWrite(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestCompareReadWrite"}
+	calls := 0
+	readAvg, writeAvg := mux.CompareReadWrite(func() {
+		calls++
+		time.Sleep(100 * time.Microsecond)
+	}, 5)
+	if readAvg < 100*time.Microsecond || writeAvg < 100*time.Microsecond {
+		t.Errorf("both averages should include the sleep, got read=%v write=%v", readAvg, writeAvg)
+	}
+	if calls != 10 {
+		t.Errorf("fn should run 5 times per mode, got %d calls", calls)
+	}
+	if st := mux.Snapshot(); st.TotalLocked != 5 || st.TotalRLocked != 5 {
+		t.Errorf("the lock operations should be counted, got %+v", st)
+	}
+
+	if r, w := mux.CompareReadWrite(func() { t.Error("fn should not run") }, 0); r != 0 || w != 0 {
+		t.Errorf("CompareReadWrite with 0 iters should return 0, 0, got %v, %v", r, w)
+	}
+}
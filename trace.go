@@ -0,0 +1,235 @@
+package loggedrwmutex
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// operation codes as written to binary trace records.
+const (
+	opLock byte = iota + 1
+	opUnlock
+	opRLock
+	opRUnlock
+)
+
+var opNames = map[byte]string{
+	opLock:    "Lock",
+	opUnlock:  "Unlock",
+	opRLock:   "RLock",
+	opRUnlock: "RUnlock",
+}
+
+// lockOp returns the code of Lock or RLock.
+func lockOp(write bool) byte {
+	if write {
+		return opLock
+	}
+	return opRLock
+}
+
+// unlockOp returns the code of Unlock or RUnlock.
+func unlockOp(write bool) byte {
+	if write {
+		return opUnlock
+	}
+	return opRUnlock
+}
+
+// opCode returns the binary code of an operation name or 0 if unknown.
+func opCode(name string) byte {
+	for code, n := range opNames {
+		if n == name {
+			return code
+		}
+	}
+	return 0
+}
+
+// binaryRecordSize is the size of one binary trace record:
+// seq uint64, nanos int64, op byte, locked uint64, rlocked uint64 (little endian).
+const binaryRecordSize = 8 + 8 + 1 + 8 + 8
+
+// Event is a single recorded mutex operation.
+// Binary trace records only carry Seq, Time, Op, Locked and RLocked.
+type Event struct {
+	Seq       uint64        // sequence number of the event per mutex, starting at 1
+	Time      time.Time     // time the event was recorded
+	Op        string        // Lock, Unlock, RLock or RUnlock
+	Name      string        // Name of the mutex
+	Locked    uint64        // lockedCount after the operation
+	RLocked   uint64        // rLockedCount after the operation
+	Goroutine int64         // id of the calling goroutine
+	Caller    string        // file:line of the call to the mutex method
+	Duration  time.Duration // hold time for Unlock and RUnlock, wait for Lock and RLock, 0 if not measured or decoded
+}
+
+// EnableBinaryTrace writes a fixed-size binary record for every operation to w.
+// This is far cheaper than formatting text logs. Pass nil to disable it again.
+// Records can be read back with DecodeBinaryTrace. Write errors are ignored.
+func (m *LoggedSyncRWMutex) EnableBinaryTrace(w io.Writer) {
+	m.mu.Lock()
+	m.feat().binaryTrace = w
+	m.mu.Unlock()
+}
+
+// record assigns the next sequence number to an operation,
+// writes it to the binary trace and retains it in the history if enabled.
+// gid is the calling goroutine if already known, d the hold or wait of op,
+// negative if not measured.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) record(op byte, gid int64, d time.Duration) {
+	m.seq++
+	trace := m.view().binaryTrace
+	if trace == nil && m.HistorySize <= 0 {
+		return
+	}
+	t := now()
+	if trace != nil {
+		var buf [binaryRecordSize]byte
+		encodeBinaryEvent(buf[:], m.seq, t.UnixNano(), op, uint64(m.lockedCount), uint64(m.rLockedCount))
+		safeWrite(trace, buf[:])
+	}
+	if m.HistorySize > 0 && m.notable(op, d) {
+		if gid == 0 {
+			gid = goid()
+		}
+		m.remember(Event{
+			Seq:       m.seq,
+			Time:      t,
+			Op:        opNames[op],
+			Name:      m.Name,
+			Locked:    uint64(m.lockedCount),
+			RLocked:   uint64(m.rLockedCount),
+			Goroutine: gid,
+			Caller:    caller(),
+			Duration:  max(d, 0),
+		})
+	}
+}
+
+// notable reports whether an event of op that held or waited d is retained
+// with HistoryMinHold and HistoryMinWait.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) notable(op byte, d time.Duration) bool {
+	if m.HistoryMinHold <= 0 && m.HistoryMinWait <= 0 {
+		return true
+	}
+	if op == opUnlock || op == opRUnlock {
+		return m.HistoryMinHold > 0 && d >= m.HistoryMinHold
+	}
+	return m.HistoryMinWait > 0 && d >= m.HistoryMinWait
+}
+
+// pkgPrefix is the prefix of the func names of this package, e.g.
+// "github.com/go-while/go-loggedrwmutex.".
+var pkgPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndexByte(name, '/') + 1
+	return name[:slash+strings.IndexByte(name[slash:], '.')+1]
+}()
+
+// caller returns file:line of the first frame outside this package, the code
+// calling the mutex method however many methods of the package are in between,
+// e.g. LockTimeout or LoggedMutexMap. Frames of _test.go files count as outside.
+func caller() string {
+	var pcs [32]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		f, more := frames.Next()
+		if outsidePackage(f) {
+			return filepath.Base(f.File) + ":" + strconv.Itoa(f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// outsidePackage reports whether f is the frame of a caller of this package, see caller.
+func outsidePackage(f runtime.Frame) bool {
+	return !strings.HasPrefix(f.Function, pkgPrefix) || strings.HasSuffix(f.File, "_test.go")
+}
+
+func encodeBinaryEvent(buf []byte, seq uint64, nanos int64, op byte, locked, rlocked uint64) {
+	binary.LittleEndian.PutUint64(buf[0:], seq)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(nanos))
+	buf[16] = op
+	binary.LittleEndian.PutUint64(buf[17:], locked)
+	binary.LittleEndian.PutUint64(buf[25:], rlocked)
+}
+
+// writeBinaryEvent encodes e as one binary trace record to w.
+func writeBinaryEvent(w io.Writer, e Event) error {
+	var buf [binaryRecordSize]byte
+	encodeBinaryEvent(buf[:], e.Seq, e.Time.UnixNano(), opCode(e.Op), e.Locked, e.RLocked)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// DecodeBinaryTrace reads binary trace records from r until EOF.
+func DecodeBinaryTrace(r io.Reader) ([]Event, error) {
+	var events []Event
+	var buf [binaryRecordSize]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if err == io.EOF {
+				return events, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return events, errors.New("loggedrwmutex: truncated binary trace record")
+			}
+			return events, err
+		}
+		name, ok := opNames[buf[16]]
+		if !ok {
+			return events, errors.New("loggedrwmutex: unknown op in binary trace record")
+		}
+		events = append(events, Event{
+			Seq:     binary.LittleEndian.Uint64(buf[0:]),
+			Time:    time.Unix(0, int64(binary.LittleEndian.Uint64(buf[8:]))),
+			Op:      name,
+			Locked:  binary.LittleEndian.Uint64(buf[17:]),
+			RLocked: binary.LittleEndian.Uint64(buf[25:]),
+		})
+	}
+}
+
+// ReplayTrace decodes a binary trace and returns the counters as they would be
+// after applying its events, e.g. to verify offline that a recorded session was balanced.
+// Name and all timing values of the result are empty.
+func ReplayTrace(r io.Reader) (Stats, error) {
+	events, err := DecodeBinaryTrace(r)
+	var st Stats
+	for _, e := range events {
+		switch e.Op {
+		case "Lock":
+			st.Locked++
+			st.TotalLocked++
+		case "Unlock":
+			if st.Locked == 0 {
+				return st, fmt.Errorf("loggedrwmutex: Unlock without Lock at seq %d", e.Seq)
+			}
+			st.Locked--
+			st.TotalUnlocked++
+		case "RLock":
+			st.RLocked++
+			st.TotalRLocked++
+		case "RUnlock":
+			if st.RLocked == 0 {
+				return st, fmt.Errorf("loggedrwmutex: RUnlock without RLock at seq %d", e.Seq)
+			}
+			st.RLocked--
+			st.TotalRUnlocked++
+		}
+	}
+	return st, err
+}
@@ -0,0 +1,106 @@
+package loggedrwmutex
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBinaryTraceRoundTrip(t *testing.T) {
+	events := []Event{
+		{Seq: 1, Time: time.Unix(0, 1000), Op: "Lock", Locked: 1, RLocked: 0},
+		{Seq: 2, Time: time.Unix(0, 2000), Op: "Unlock", Locked: 0, RLocked: 0},
+		{Seq: 3, Time: time.Unix(0, 3000), Op: "RLock", Locked: 0, RLocked: 1},
+		{Seq: 4, Time: time.Unix(0, 4000), Op: "RUnlock", Locked: 0, RLocked: 0},
+	}
+	var buf bytes.Buffer
+	for _, e := range events {
+		if err := writeBinaryEvent(&buf, e); err != nil {
+			t.Fatalf("writeBinaryEvent failed: %v", err)
+		}
+	}
+	if buf.Len() != len(events)*binaryRecordSize {
+		t.Errorf("trace should be %d bytes, got %d", len(events)*binaryRecordSize, buf.Len())
+	}
+	decoded, err := DecodeBinaryTrace(&buf)
+	if err != nil {
+		t.Fatalf("DecodeBinaryTrace failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, events) {
+		t.Errorf("decoded events should equal encoded events\nwant %+v\ngot  %+v", events, decoded)
+	}
+
+	// truncated record
+	if _, err := DecodeBinaryTrace(bytes.NewReader(make([]byte, binaryRecordSize-1))); err == nil {
+		t.Error("DecodeBinaryTrace should fail on a truncated record")
+	}
+}
+
+func TestEnableBinaryTrace(t *testing.T) {
+	mux := &LoggedSyncRWMutex{Name: "TestBinaryTrace"}
+	var buf bytes.Buffer
+	mux.EnableBinaryTrace(&buf)
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.EnableBinaryTrace(nil)
+	mux.Lock() // not traced
+	mux.Unlock()
+
+	events, err := DecodeBinaryTrace(&buf)
+	if err != nil {
+		t.Fatalf("DecodeBinaryTrace failed: %v", err)
+	}
+	wantOps := []string{"Lock", "Unlock", "RLock", "RUnlock"}
+	if len(events) != len(wantOps) {
+		t.Fatalf("should decode %d events, got %d", len(wantOps), len(events))
+	}
+	for i, e := range events {
+		if e.Op != wantOps[i] {
+			t.Errorf("event %d should be %s, got %s", i, wantOps[i], e.Op)
+		}
+		if e.Seq != uint64(i+1) {
+			t.Errorf("event %d should have seq %d, got %d", i, i+1, e.Seq)
+		}
+	}
+	if events[0].Locked != 1 || events[2].RLocked != 1 {
+		t.Errorf("counts not recorded: %+v", events)
+	}
+}
+
+func TestReplayTrace(t *testing.T) {
+	var trace bytes.Buffer
+	mux := &LoggedSyncRWMutex{Name: "TestReplayTrace"}
+	mux.EnableBinaryTrace(&trace)
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+	mux.RLock()
+	mux.RUnlock()
+	mux.RUnlock()
+	mux.Lock()
+	mux.Unlock()
+	mux.RLock()
+
+	st, err := ReplayTrace(bytes.NewReader(trace.Bytes()))
+	if err != nil {
+		t.Fatalf("ReplayTrace failed: %v", err)
+	}
+	live := mux.Snapshot()
+	if !StatsEqual(st, live) || st.Locked != live.Locked || st.RLocked != live.RLocked {
+		t.Errorf("replayed stats %+v should match the live stats %+v", st, live)
+	}
+	mux.RUnlock()
+
+	// an unbalanced trace
+	var bad bytes.Buffer
+	writeBinaryEvent(&bad, Event{Seq: 1, Op: "Lock"})
+	writeBinaryEvent(&bad, Event{Seq: 2, Op: "Unlock"})
+	writeBinaryEvent(&bad, Event{Seq: 3, Op: "Unlock"})
+	if _, err := ReplayTrace(&bad); err == nil || !strings.Contains(err.Error(), "seq 3") {
+		t.Errorf("ReplayTrace should fail at seq 3, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package loggedrwmutex
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// WarnOutput receives warnings about suspicious mutex usage.
+var WarnOutput io.Writer = os.Stderr
+
+// WarnSuppressWindow limits identical warnings (same mutex, same kind) to one per window.
+// Duplicates within the window are counted and reported as "(suppressed N duplicates)"
+// with the next warning of that kind after the window has ended, or when the window
+// ends if no further warning comes. 0 disables suppression.
+var WarnSuppressWindow time.Duration
+
+// now is the clock used for all timestamps, replaced in tests.
+var now = time.Now
+
+// warnState tracks the last emission of one kind of warning.
+type warnState struct {
+	last       time.Time
+	suppressed uint64
+	flushing   bool // a timer writes the summary at the end of the window
+}
+
+// Severity is the level of a warning, see MinWarnSeverity.
+type Severity int8
+
+const (
+	SeverityInfo     Severity = iota // notable usage patterns such as trivial holds
+	SeverityWarn                     // detected misuse and degraded logging
+	SeverityCritical                 // suspected deadlocks
+)
+
+var severityNames = [...]string{"INFO", "WARN", "CRITICAL"}
+
+func (s Severity) String() string {
+	if int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", s)
+}
+
+// MinWarnSeverity suppresses warnings below this severity.
+var MinWarnSeverity = SeverityInfo
+
+// warnf writes a warning of the given severity and kind to WarnOutput,
+// subject to MinWarnSeverity and WarnSuppressWindow. With JSONLines it is
+// written as JSON object.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) warnf(sev Severity, kind string, format string, args ...any) {
+	if sev < MinWarnSeverity {
+		return
+	}
+	t := now()
+	if WarnSuppressWindow > 0 {
+		f := m.feat()
+		if f.warnings == nil {
+			f.warnings = make(map[string]*warnState)
+		}
+		st := f.warnings[kind]
+		if st == nil {
+			st = &warnState{}
+			f.warnings[kind] = st
+		} else if t.Sub(st.last) < WarnSuppressWindow {
+			st.suppressed++
+			if !st.flushing {
+				st.flushing = true
+				m.flushWarningAfter(sev, kind, st, WarnSuppressWindow-t.Sub(st.last))
+			}
+			return
+		}
+		if st.suppressed > 0 {
+			m.writeWarning(sev, kind, fmt.Sprintf("(suppressed %d duplicates)", st.suppressed), t)
+			st.suppressed = 0
+		}
+		st.last = t
+	}
+	m.writeWarning(sev, kind, fmt.Sprintf(format, args...), t)
+}
+
+// flushWarningAfter writes the summary of the duplicates counted in st after d,
+// unless a warning of that kind has written it before.
+func (m *LoggedSyncRWMutex) flushWarningAfter(sev Severity, kind string, st *warnState, d time.Duration) {
+	time.AfterFunc(d, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		st.flushing = false
+		if st.suppressed > 0 {
+			m.writeWarning(sev, kind, fmt.Sprintf("(suppressed %d duplicates)", st.suppressed), now())
+			st.suppressed = 0
+		}
+	})
+}
+
+// writeWarning writes one warning line of m to WarnOutput.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) writeWarning(sev Severity, kind string, msg string, t time.Time) {
+	writeWarning(m.JSONLines, m.logName(), sev, kind, msg, t)
+}
+
+// writeWarning writes one warning line of the mutex name to WarnOutput,
+// as JSON object with JSONLines or jsonLines set.
+func writeWarning(jsonLines bool, name string, sev Severity, kind string, msg string, t time.Time) {
+	if JSONLines || jsonLines {
+		warnJSON(name, sev, kind, msg, t)
+		return
+	}
+	safeFprintf(WarnOutput, "[loggedMUTEX] %s '%s' %s: %s\n", sev, name, kind, msg)
+}
+
+// MisuseKind classifies a MisuseError.
+type MisuseKind int8
+
+const (
+	MisuseOrder        MisuseKind = iota // a violated EnforceOrder rule
+	MisuseSelfDeadlock                   // a lock the calling goroutine blocks itself on
+	MisuseInvariant                      // inconsistent counters, see CheckInvariants
+	MisuseWeight                         // a negative LockWeighted weight
+	MisuseUnbalanced                     // locks without unlocks, see CheckBalanced
+	MisuseOwnership                      // a failed StrictOwnership check, AssertHeld or AssertRHeld
+	MisuseLeak                           // held mutexes found by CheckLeaks with LeakPanic
+)
+
+var misuseKindNames = [...]string{"order", "self-deadlock", "invariant", "weight", "unbalanced", "ownership", "leak"}
+
+func (k MisuseKind) String() string {
+	if int(k) < len(misuseKindNames) {
+		return misuseKindNames[k]
+	}
+	return fmt.Sprintf("MisuseKind(%d)", k)
+}
+
+// MisuseError describes a misuse of a mutex. It is the panic value of misuse
+// under PanicOnMisuse, StrictOwnership, AssertHeld, AssertRHeld and LeakPanic
+// and the error returned by CheckBalanced and CheckInvariants.
+type MisuseError struct {
+	Name string     // Name of the mutex, empty for CheckLeaks
+	Op   string     // operation that detected the misuse, e.g. "Lock", empty for the Check helpers
+	Kind MisuseKind // what was misused
+	Msg  string     // details
+}
+
+func (e *MisuseError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("loggedrwmutex: %s: %s", e.Kind, e.Msg)
+	}
+	return fmt.Sprintf("loggedrwmutex: '%s' %s: %s", e.Name, e.Kind, e.Msg)
+}
+
+// misuseError returns a MisuseError of m detected by op, 0 for none.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) misuseError(kind MisuseKind, op byte, format string, args ...any) *MisuseError {
+	return &MisuseError{Name: m.logName(), Op: opNames[op], Kind: kind, Msg: fmt.Sprintf(format, args...)}
+}
+
+// PanicOnMisuse makes detected misuse (e.g. a violated EnforceOrder rule) panic
+// with a *MisuseError instead of writing a warning to WarnOutput.
+var PanicOnMisuse = false
+
+// misuse reports a misuse of the mutex detected by op according to PanicOnMisuse.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) misuse(kind MisuseKind, op byte, format string, args ...any) {
+	m.reportMisuse(m.misuseError(kind, op, format, args...))
+}
+
+// reportMisuse panics with e under PanicOnMisuse or writes it as warning.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) reportMisuse(e *MisuseError) {
+	if PanicOnMisuse {
+		panic(e)
+	}
+	m.warnf(SeverityWarn, e.Kind.String(), "%s", e.Msg)
+}
+
+// WarnInitLocks makes Lock and RLock warn (once per mutex) when they are called
+// before MarkMainStarted, e.g. from a package init func.
+var WarnInitLocks = false
+
+// mainStarted is set by MarkMainStarted.
+var mainStarted atomic.Bool
+
+// MarkMainStarted marks the end of package initialization for WarnInitLocks,
+// call it first thing in main.
+func MarkMainStarted() {
+	mainStarted.Store(true)
+}
+
+// checkInit warns about a lock taken during initialization.
+// Must be called with m.mu held.
+func (m *LoggedSyncRWMutex) checkInit(write bool) {
+	if !WarnInitLocks || m.initWarned || mainStarted.Load() {
+		return
+	}
+	m.initWarned = true
+	op := "RLock"
+	if write {
+		op = "Lock"
+	}
+	m.warnf(SeverityInfo, "init-lock", "%s called before MarkMainStarted, lock taken during initialization", op)
+}
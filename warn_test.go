@@ -0,0 +1,187 @@
+package loggedrwmutex
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarnSuppressWindow(t *testing.T) {
+	clock := useFakeClock(t)
+	buf := captureWarnings(t)
+	WarnSuppressWindow = time.Second
+	defer func() { WarnSuppressWindow = 0 }()
+
+	mux := &LoggedSyncRWMutex{Name: "TestWarnSuppress"}
+	mux.mu.Lock()
+	for i := 0; i < 5; i++ {
+		mux.warnf(SeverityCritical, "deadlock", "possible deadlock")
+		clock.advance(100 * time.Millisecond)
+	}
+	// a different kind is not suppressed by the first one
+	mux.warnf(SeverityWarn, "starvation", "reader starved")
+	mux.mu.Unlock()
+
+	got := lines(buf)
+	if len(got) != 2 {
+		t.Fatalf("should emit 2 lines within the window, got %d: %q", len(got), got)
+	}
+	if !strings.Contains(got[0], "deadlock: possible deadlock") {
+		t.Errorf("unexpected first warning: %q", got[0])
+	}
+
+	buf.Reset()
+	clock.advance(time.Second)
+	mux.mu.Lock()
+	mux.warnf(SeverityCritical, "deadlock", "possible deadlock")
+	mux.mu.Unlock()
+	got = lines(buf)
+	if len(got) != 2 {
+		t.Fatalf("should emit summary and warning after the window, got %d: %q", len(got), got)
+	}
+	if !strings.Contains(got[0], "(suppressed 4 duplicates)") {
+		t.Errorf("summary should report 4 duplicates, got %q", got[0])
+	}
+	if !strings.Contains(got[1], "possible deadlock") {
+		t.Errorf("unexpected warning after the window: %q", got[1])
+	}
+}
+
+func TestWarnSuppressWindowFlush(t *testing.T) {
+	buf := &syncBuffer{}
+	orig := WarnOutput
+	WarnOutput = buf
+	defer func() { WarnOutput = orig }()
+	WarnSuppressWindow = 20 * time.Millisecond
+	defer func() { WarnSuppressWindow = 0 }()
+
+	mux := &LoggedSyncRWMutex{Name: "TestWarnSuppressFlush"}
+	mux.mu.Lock()
+	for i := 0; i < 3; i++ {
+		mux.warnf(SeverityWarn, "starvation", "reader starved")
+	}
+	mux.mu.Unlock()
+
+	// no further warning comes, the window end writes the summary
+	want := "[loggedMUTEX] WARN 'TestWarnSuppressFlush' starvation: (suppressed 2 duplicates)"
+	for i := 0; i < 100 && !strings.Contains(buf.String(), want); i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("summary %q should be written at the end of the window, got %q", want, got)
+	}
+}
+
+func TestWarnWithoutSuppressWindow(t *testing.T) {
+	buf := captureWarnings(t)
+	mux := &LoggedSyncRWMutex{Name: "TestWarnNoSuppress"}
+	mux.mu.Lock()
+	for i := 0; i < 3; i++ {
+		mux.warnf(SeverityCritical, "deadlock", "possible deadlock")
+	}
+	mux.mu.Unlock()
+	if got := lines(buf); len(got) != 3 {
+		t.Errorf("should emit every warning without a window, got %d", len(got))
+	}
+}
+
+func TestWarnInitLocks(t *testing.T) {
+	buf := captureWarnings(t)
+	WarnInitLocks = true
+	started := mainStarted.Load()
+	mainStarted.Store(false)
+	defer func() {
+		WarnInitLocks = false
+		mainStarted.Store(started)
+	}()
+
+	early := &LoggedSyncRWMutex{Name: "TestWarnInitLocks"}
+	early.RLock()
+	early.RUnlock()
+	early.Lock()
+	early.Unlock()
+	got := lines(buf)
+	if len(got) != 1 || !strings.Contains(got[0], "init-lock: RLock called before MarkMainStarted") {
+		t.Fatalf("should warn once about the lock during initialization, got %q", got)
+	}
+
+	buf.Reset()
+	MarkMainStarted()
+	late := &LoggedSyncRWMutex{Name: "TestWarnInitLocksLate"}
+	late.Lock()
+	late.Unlock()
+	if buf.Len() != 0 {
+		t.Errorf("should not warn after MarkMainStarted, got %q", buf.String())
+	}
+}
+
+func TestMinWarnSeverity(t *testing.T) {
+	buf := captureWarnings(t)
+	MinWarnSeverity = SeverityCritical
+	defer func() { MinWarnSeverity = SeverityInfo }()
+	mux := &LoggedSyncRWMutex{Name: "TestMinWarnSeverity", DeadlockTimeout: 10 * time.Millisecond}
+
+	mux.LockWeighted(-1) // Warn: negative weight
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.RLock() // Critical: waits past DeadlockTimeout
+		mux.RUnlock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mux.Unlock()
+	<-done
+
+	got := lines(buf)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "[loggedMUTEX] CRITICAL 'TestMinWarnSeverity' deadlock: RLock waiting longer than 10ms") {
+		t.Errorf("only the critical deadlock warning should be emitted, got %q", got)
+	}
+
+	// the severity is a field of JSON warnings
+	buf.Reset()
+	MinWarnSeverity = SeverityInfo
+	mux.JSONLines = true
+	mux.mu.Lock()
+	mux.warnf(SeverityWarn, "weight", "negative weight")
+	mux.mu.Unlock()
+	var w jsonWarning
+	if err := json.Unmarshal(buf.Bytes(), &w); err != nil || w.Severity != "WARN" || w.Warning != "weight" || w.Name != "TestMinWarnSeverity" || w.Msg != "negative weight" {
+		t.Errorf("should write the warning as JSON with its severity, got %q (%v)", buf.String(), err)
+	}
+}
+
+func TestMisuseError(t *testing.T) {
+	PanicOnMisuse = true
+	defer func() { PanicOnMisuse = false }()
+	mux := &LoggedSyncRWMutex{Name: "TestMisuseError", DetectLockThenRLock: true}
+
+	mux.Lock()
+	p := recovered(func() { mux.RLock() })
+	mux.Unlock()
+	e, ok := p.(*MisuseError)
+	if !ok {
+		t.Fatalf("PanicOnMisuse should panic with a *MisuseError, got %T %v", p, p)
+	}
+	if e.Kind != MisuseSelfDeadlock || e.Op != "RLock" || e.Name != "TestMisuseError" {
+		t.Errorf("should be a self-deadlock of RLock, got %+v", e)
+	}
+	want := "loggedrwmutex: 'TestMisuseError' self-deadlock: goroutine " + strconv.FormatInt(goid(), 10) + " calls RLock while holding the write lock, this never returns"
+	if e.Error() != want {
+		t.Errorf("Error should be %q, got %q", want, e.Error())
+	}
+
+	// the warning is unchanged without PanicOnMisuse
+	PanicOnMisuse = false
+	buf := captureWarnings(t)
+	mux.LockWeighted(-1)
+	mux.Unlock()
+	if got := lines(buf); len(got) != 1 || got[0] != "[loggedMUTEX] WARN 'TestMisuseError' weight: negative weight -1 is not counted" {
+		t.Errorf("should warn about the weight, got %q", got)
+	}
+
+	if s := MisuseKind(9).String(); s != "MisuseKind(9)" {
+		t.Errorf("unknown kind should format as MisuseKind(9), got %q", s)
+	}
+}